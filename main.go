@@ -5,8 +5,11 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/Sahil2k07/Blog-App-Go/src/config"
+	"github.com/Sahil2k07/Blog-App-Go/src/jobs"
+	"github.com/Sahil2k07/Blog-App-Go/src/middlewares"
 	"github.com/Sahil2k07/Blog-App-Go/src/routes"
 	"github.com/gorilla/handlers"
 	"github.com/joho/godotenv"
@@ -26,21 +29,40 @@ func main() {
 	corsHandler := handlers.CORS(
 		handlers.AllowedOrigins(origins),
 		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}),
-		handlers.AllowedHeaders([]string{"Content-Type", "Authorization"}),
+		handlers.AllowedHeaders([]string{"Content-Type", "Authorization", "X-Request-ID"}),
+		handlers.ExposedHeaders([]string{"X-Request-ID"}),
 	)
 
-	config.InitBloomFilter(1000000, 0.01)
+	config.InitOAuthProviders()
 
 	// Database
 	config.DBConnect()
 	defer config.DBDisconnect()
 
+	config.BackfillCanonicalEmails()
+
+	config.PromoteInitialAdmin()
+
+	config.WarmBloomFilter(0.01)
+
+	config.InitJWTKeys()
+
+	middlewares.InitRateLimiter(os.Getenv("RATE_LIMIT_BACKEND"))
+
+	// Background Jobs
+	jobs.StartAccountPurger(time.Hour)
+	jobs.StartDataExportCleaner(time.Hour)
+	jobs.StartLoginFailureCleaner(time.Hour)
+	jobs.StartPowChallengeCleaner(time.Hour)
+	jobs.StartBloomFilterSync(10 * time.Minute)
+	jobs.StartJWTKeyRotator(config.JWTKeyReloadInterval)
+
 	// Routes
 	router := routes.AppRoutes()
 
 	log.Printf("Server is running on %s", PORT)
 
-	err := http.ListenAndServe(PORT, corsHandler(router))
+	err := http.ListenAndServe(PORT, corsHandler(middlewares.RequestID(router)))
 	if err != nil {
 		log.Fatalf("Could not start server: %s\n", err.Error())
 	}
@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/config"
+	"github.com/Sahil2k07/Blog-App-Go/src/middlewares"
+	"github.com/Sahil2k07/Blog-App-Go/src/utils"
+	"github.com/google/uuid"
+)
+
+// Types of security events
+const (
+	LoginSucceeded           = "login_succeeded"
+	LoginFailed              = "login_failed"
+	AccountLocked            = "account_locked"
+	Logout                   = "logout"
+	OtpVerified              = "otp_verified"
+	OtpFailed                = "otp_failed"
+	PasswordChanged          = "password_changed"
+	PasswordReset            = "password_reset"
+	EmailChanged             = "email_changed"
+	ProfileImageChanged      = "profile_image_changed"
+	TwoFactorEnabled         = "two_factor_enabled"
+	TwoFactorDisabled        = "two_factor_disabled"
+	SessionRevoked           = "session_revoked"
+	TokenCreated             = "token_created"
+	TokenRevoked             = "token_revoked"
+	AccountDeletionRequested = "account_deletion_requested"
+	AccountRestored          = "account_restored"
+	EmailDeliveryFailed      = "email_delivery_failed"
+	InviteCreated            = "invite_created"
+	InviteRevoked            = "invite_revoked"
+	RoleChanged              = "role_changed"
+	BlogUnpublished          = "blog_unpublished"
+	BlogDeletedByAdmin       = "blog_deleted_by_admin"
+)
+
+// Extra details of an event, stored as JSON
+type Details map[string]interface{}
+
+// Append an event to the audit log. userId and email may be empty when they are unknown,
+// e.g. a failed login for an email without an account. Failures are logged, never returned,
+// so auditing cannot break the request it describes.
+func Record(r *http.Request, eventType, userId, email string, details Details) {
+	var detailsJSON []byte
+
+	if len(details) > 0 {
+		var err error
+		if detailsJSON, err = json.Marshal(details); err != nil {
+			log.Printf("Failed to encode audit event %s: %v", eventType, err)
+		}
+	}
+
+	if len(email) > 255 {
+		email = email[:255]
+	}
+
+	var userAgent string
+	if r != nil {
+		userAgent = r.UserAgent()
+		if len(userAgent) > 255 {
+			userAgent = userAgent[:255]
+		}
+	}
+
+	_, err := config.DB.Exec(
+		`
+			INSERT INTO AuditEvent (id, eventType, userId, email, ipAddress, userAgent, requestId, details)
+			VALUES (?, ?, NULLIF(?, ''), NULLIF(?, ''), ?, ?, ?, ?)
+		`, uuid.New().String(), eventType, userId, email, clientIP(r), userAgent, requestID(r), detailsJSON,
+	)
+	if err != nil {
+		log.Printf("Failed to record audit event %s for %q: %v", eventType, userId, err)
+	}
+}
+
+func clientIP(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+	return utils.ClientIP(r)
+}
+
+func requestID(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+	return middlewares.GetRequestID(r)
+}
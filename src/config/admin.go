@@ -0,0 +1,32 @@
+package config
+
+import (
+	"log"
+	"os"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/utils"
+)
+
+// Make the account with ADMIN_EMAIL an Admin, so a new instance has someone to hand out roles
+func PromoteInitialAdmin() {
+	email := utils.NormalizeEmail(os.Getenv("ADMIN_EMAIL"))
+	if email == "" {
+		return
+	}
+
+	result, err := DB.Exec(
+		`
+			UPDATE User
+			SET role = 'admin'
+			WHERE email = ? AND role <> 'admin'
+		`, email,
+	)
+	if err != nil {
+		log.Printf("Failed to promote %s to admin: %v", email, err)
+		return
+	}
+
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected > 0 {
+		log.Println("Promoted", email, "to admin")
+	}
+}
@@ -1,35 +1,495 @@
 package config
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/bits-and-blooms/bloom/v3"
+	"github.com/Sahil2k07/Blog-App-Go/src/utils"
 )
 
-var EmailBloomFilter *bloom.BloomFilter
+// Emails of User rows, keyed by utils.CanonicalEmail. Only the sync changes it, so every row is
+// counted exactly once and only emails it counted are removed.
+var EmailBloomFilter *utils.CountingBloomFilter
+
+// Guards EmailBloomFilter and the sync state below, the filter itself is not safe for concurrent use
+var emailBloomLock sync.RWMutex
+
+var (
+	// Number of emails the filter was sized for
+	emailBloomCapacity uint
+	// False positive rate the filter was sized for
+	emailBloomFalsePositiveRate float64
+	// Whether the filter was filled from the User table
+	emailBloomFilled bool
+	// Cursor of the last User row in the filter, in (emailSetAt, id) order. Empty before the first row.
+	emailBloomSyncedAt string
+	emailBloomSyncedId string
+	// Cursor of the last EmailBloomRemoval applied, in (createdAt, id) order. Empty before the first one.
+	emailBloomRemovedAt string
+	emailBloomRemovedId string
+)
+
+// Rows whose email was set this recently are left for the next sync, so a transaction that
+// commits a bit after its timestamp is not skipped by the cursor
+const emailBloomSettleTime = 5 * time.Second
+
+// Removals older than this are deleted, a filter that missed them only keeps extra emails until its rebuild
+const emailBloomRemovalRetention = 7 * 24 * time.Hour
+
+// Smallest capacity the filter is created with, so a new app has room to grow
+const minEmailBloomCapacity = 10000
 
 // Initialize Bloom Filter
 // size: number of expected items to be added to the filter
 // falsePositiveRate: acceptable false positive rate (between 0 and 1)
 func InitBloomFilter(size uint, falsePositiveRate float64) {
-	EmailBloomFilter = bloom.NewWithEstimates(size, falsePositiveRate)
-	log.Println("Bloom filter initialized with size:", size, "and false positive rate:", falsePositiveRate)
-}
+	emailBloomLock.Lock()
+	defer emailBloomLock.Unlock()
+
+	EmailBloomFilter = utils.NewCountingBloomFilter(size, falsePositiveRate)
+	emailBloomCapacity = size
+	emailBloomFalsePositiveRate = falsePositiveRate
+	emailBloomFilled = false
+	emailBloomSyncedAt = ""
+	emailBloomSyncedId = ""
+	emailBloomRemovedAt = ""
+	emailBloomRemovedId = ""
 
-// Add email to Bloom Filter
-func AddEmailToBloom(email string) {
-	EmailBloomFilter.AddString(email)
+	log.Println("Bloom filter initialized with size:", size, "and false positive rate:", falsePositiveRate)
 }
 
 // Check if email might exist in Bloom Filter
 func CheckEmailInBloom(email string) bool {
-	return EmailBloomFilter.TestString(email)
+	emailBloomLock.RLock()
+	defer emailBloomLock.RUnlock()
+
+	return EmailBloomFilter.TestString(utils.CanonicalEmail(email))
+}
+
+// Queue the current email of a user for removal from every filter. Call it in the transaction that
+// deletes the user or changes the email, before the change, so the removal commits with it. A signup
+// that rolls back needs nothing, its row never committed and no sync counted it.
+func RemoveEmailFromBloom(ctx context.Context, q Querier, userId string) error {
+	_, err := q.ExecContext(ctx,
+		`
+			INSERT INTO EmailBloomRemoval (id, userId, email, emailSetAt)
+			SELECT UUID(), id, email, emailSetAt FROM User
+			WHERE id = ?
+		`, userId,
+	)
+
+	return err
+}
+
+// Whether the filter holds every registered email, so a miss can be trusted
+func EmailBloomWarm() bool {
+	emailBloomLock.RLock()
+	defer emailBloomLock.RUnlock()
+
+	return emailBloomFilled
+}
+
+// Size the filter from the User table and fill it, starting from the last snapshot when it is still big enough
+func WarmBloomFilter(falsePositiveRate float64) {
+	var userCount uint
+
+	if err := DB.QueryRow(`SELECT COUNT(*) FROM User`).Scan(&userCount); err != nil {
+		log.Printf("Failed to count users for the Bloom filter: %v", err)
+
+		// Stays cold, SignUp checks the database for every email until the next sync
+		InitBloomFilter(minEmailBloomCapacity, falsePositiveRate)
+		return
+	}
+
+	if err := loadBloomSnapshot(userCount); err != nil {
+		log.Printf("Bloom filter snapshot not used: %v", err)
+
+		InitBloomFilter(emailBloomCapacityFor(userCount), falsePositiveRate)
+	}
+
+	if err := SyncBloomFilter(); err != nil {
+		log.Printf("Failed to fill the Bloom filter: %v", err)
+		return
+	}
+
+	log.Println("Bloom filter warmed with", userCount, "registered emails")
+}
+
+// Twice the current users, so the false positive rate holds while the app grows
+func emailBloomCapacityFor(userCount uint) uint {
+	return max(2*userCount, minEmailBloomCapacity)
+}
+
+// Take out the emails removed since the last sync, then stream the emails of User rows set since into
+// the filter. The filter is rebuilt at a bigger size once more users exist than it was sized for.
+func SyncBloomFilter() error {
+	return syncBloomFilter(false)
+}
+
+// Build a new filter from every User row, dropping emails whose removal was missed, e.g. while the
+// instance was down for longer than removals are kept. Old removals are deleted afterwards.
+func RebuildBloomFilter() error {
+	if err := syncBloomFilter(true); err != nil {
+		return err
+	}
+
+	_, err := DB.Exec(
+		`
+			DELETE FROM EmailBloomRemoval
+			WHERE createdAt < NOW(6) - INTERVAL ? SECOND
+		`, int(emailBloomRemovalRetention.Seconds()),
+	)
+
+	return err
+}
+
+func syncBloomFilter(rebuild bool) error {
+	// Users and removals are read from one snapshot, so a removal shows up exactly when its row is gone
+	tx, err := DB.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var userCount uint
+
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM User`).Scan(&userCount); err != nil {
+		return err
+	}
+
+	emailBloomLock.RLock()
+	filter, syncedAt, syncedId := EmailBloomFilter, emailBloomSyncedAt, emailBloomSyncedId
+	removedAt, removedId := emailBloomRemovedAt, emailBloomRemovedId
+	capacity, falsePositiveRate := emailBloomCapacity, emailBloomFalsePositiveRate
+	emailBloomLock.RUnlock()
+
+	// Removals before the cursor belong to rows the new filter never sees, so it keeps the cursor
+	if rebuild || userCount > capacity {
+		capacity = max(capacity, emailBloomCapacityFor(userCount))
+		filter = utils.NewCountingBloomFilter(capacity, falsePositiveRate)
+		syncedAt, syncedId = "", ""
+	}
+
+	// Removals come before new rows and are compared with the cursor from before this sync. A removed
+	// email at or before it was counted by an earlier sync. One after it belongs to a row that was gone
+	// before any sync reached it, so it was never counted and is skipped. A removal that commits behind
+	// the cursor is missed, which only leaves an extra email until the next rebuild.
+	removals, err := tx.Query(
+		`
+			SELECT id, email, createdAt,
+				? <> '' AND (emailSetAt < ? OR (emailSetAt = ? AND userId <= ?))
+			FROM EmailBloomRemoval
+			WHERE ? = '' OR createdAt > ? OR (createdAt = ? AND id > ?)
+			ORDER BY createdAt, id
+		`, syncedAt, syncedAt, syncedAt, syncedId, removedAt, removedAt, removedAt, removedId,
+	)
+	if err != nil {
+		return err
+	}
+
+	for removals.Next() {
+		var id, email, createdAt string
+		var counted bool
+		if err = removals.Scan(&id, &email, &createdAt, &counted); err != nil {
+			break
+		}
+
+		if counted {
+			emailBloomLock.Lock()
+			filter.RemoveString(utils.CanonicalEmail(email))
+			emailBloomLock.Unlock()
+		}
+
+		removedAt, removedId = createdAt, id
+	}
+	if err == nil {
+		err = removals.Err()
+	}
+	removals.Close()
+
+	if err != nil {
+		return commitBloomSyncError(filter, syncedAt, syncedId, removedAt, removedId, err)
+	}
+
+	// Strictly after the cursor, so no row is added twice. A changed email moves its row
+	// past the cursor again and only the new email is added.
+	rows, err := tx.Query(
+		`
+			SELECT id, email, emailSetAt FROM User
+			WHERE (? = '' OR emailSetAt > ? OR (emailSetAt = ? AND id > ?))
+				AND emailSetAt < NOW(6) - INTERVAL ? MICROSECOND
+			ORDER BY emailSetAt, id
+		`, syncedAt, syncedAt, syncedAt, syncedId, emailBloomSettleTime.Microseconds(),
+	)
+	if err != nil {
+		return commitBloomSyncError(filter, syncedAt, syncedId, removedAt, removedId, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, email, emailSetAt string
+		if err = rows.Scan(&id, &email, &emailSetAt); err != nil {
+			break
+		}
+
+		emailBloomLock.Lock()
+		filter.AddString(utils.CanonicalEmail(email))
+		emailBloomLock.Unlock()
+
+		syncedAt, syncedId = emailSetAt, id
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+
+	if err != nil {
+		return commitBloomSyncError(filter, syncedAt, syncedId, removedAt, removedId, err)
+	}
+
+	emailBloomLock.Lock()
+	defer emailBloomLock.Unlock()
+
+	if filter != EmailBloomFilter {
+		log.Println("Bloom filter rebuilt for", capacity, "emails")
+		EmailBloomFilter = filter
+		emailBloomCapacity = capacity
+	}
+	emailBloomFilled = true
+	emailBloomSyncedAt = syncedAt
+	emailBloomSyncedId = syncedId
+	emailBloomRemovedAt = removedAt
+	emailBloomRemovedId = removedId
+
+	return nil
+}
+
+// Keep the progress of a sync that failed halfway. Changes made to the live filter stay, so its
+// cursors have to move past them. A filter being rebuilt is dropped.
+func commitBloomSyncError(filter *utils.CountingBloomFilter, syncedAt, syncedId, removedAt, removedId string, err error) error {
+	emailBloomLock.Lock()
+	defer emailBloomLock.Unlock()
+
+	if filter == EmailBloomFilter {
+		emailBloomSyncedAt, emailBloomSyncedId = syncedAt, syncedId
+		emailBloomRemovedAt, emailBloomRemovedId = removedAt, removedId
+	}
+
+	return err
+}
+
+// Snapshots go to BLOOM_SNAPSHOT_PATH when set, otherwise to the BloomSnapshot table
+const emailBloomSnapshotName = "email"
+
+// Bumped whenever the snapshot layout changes, older snapshots are ignored
+const emailBloomSnapshotVersion uint8 = 5
+
+// Save the filter so the next boot only has to catch up on the rows changed since
+func SnapshotBloomFilter() error {
+	var snapshot bytes.Buffer
+
+	emailBloomLock.RLock()
+	if !emailBloomFilled {
+		emailBloomLock.RUnlock()
+		return errors.New("bloom filter is not warm yet")
+	}
+
+	snapshot.WriteByte(emailBloomSnapshotVersion)
+	binary.Write(&snapshot, binary.BigEndian, uint64(emailBloomCapacity))
+	binary.Write(&snapshot, binary.BigEndian, emailBloomFalsePositiveRate)
+	binary.Write(&snapshot, binary.BigEndian, uint16(len(emailBloomSyncedAt)))
+	snapshot.WriteString(emailBloomSyncedAt)
+	binary.Write(&snapshot, binary.BigEndian, uint16(len(emailBloomSyncedId)))
+	snapshot.WriteString(emailBloomSyncedId)
+	binary.Write(&snapshot, binary.BigEndian, uint16(len(emailBloomRemovedAt)))
+	snapshot.WriteString(emailBloomRemovedAt)
+	binary.Write(&snapshot, binary.BigEndian, uint16(len(emailBloomRemovedId)))
+	snapshot.WriteString(emailBloomRemovedId)
+
+	_, err := EmailBloomFilter.WriteTo(&snapshot)
+	emailBloomLock.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	if path := os.Getenv("BLOOM_SNAPSHOT_PATH"); path != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return err
+		}
+
+		// Write next to the old snapshot and swap, so a crash never leaves half a file
+		tmpPath := path + ".tmp"
+		if err := os.WriteFile(tmpPath, snapshot.Bytes(), 0o600); err != nil {
+			return err
+		}
+
+		return os.Rename(tmpPath, path)
+	}
+
+	_, err = DB.Exec(
+		`
+			INSERT INTO BloomSnapshot (name, data)
+			VALUES (?, ?)
+			ON DUPLICATE KEY UPDATE data = VALUES(data)
+		`, emailBloomSnapshotName, snapshot.Bytes(),
+	)
+
+	return err
+}
+
+// Replace the filter with the last snapshot, unless it is too small for the users there are now
+func loadBloomSnapshot(userCount uint) error {
+	var reader io.Reader
+
+	if path := os.Getenv("BLOOM_SNAPSHOT_PATH"); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		reader = bufio.NewReader(file)
+	} else {
+		var data []byte
+
+		err := DB.QueryRow(`SELECT data FROM BloomSnapshot WHERE name = ?`, emailBloomSnapshotName).Scan(&data)
+		if err != nil {
+			return err
+		}
+
+		reader = bytes.NewReader(data)
+	}
+
+	var version uint8
+	var capacity uint64
+	var falsePositiveRate float64
+
+	if err := binary.Read(reader, binary.BigEndian, &version); err != nil {
+		return err
+	}
+	if version != emailBloomSnapshotVersion {
+		return fmt.Errorf("snapshot has version %d, expected %d", version, emailBloomSnapshotVersion)
+	}
+	if err := binary.Read(reader, binary.BigEndian, &capacity); err != nil {
+		return err
+	}
+	if err := binary.Read(reader, binary.BigEndian, &falsePositiveRate); err != nil {
+		return err
+	}
+
+	syncedAt, err := readSnapshotString(reader)
+	if err != nil {
+		return err
+	}
+	syncedId, err := readSnapshotString(reader)
+	if err != nil {
+		return err
+	}
+	removedAt, err := readSnapshotString(reader)
+	if err != nil {
+		return err
+	}
+	removedId, err := readSnapshotString(reader)
+	if err != nil {
+		return err
+	}
+
+	if uint(capacity) < userCount {
+		return fmt.Errorf("snapshot sized for %d emails, %d users exist", capacity, userCount)
+	}
+
+	filter := &utils.CountingBloomFilter{}
+	if _, err := filter.ReadFrom(reader); err != nil {
+		return err
+	}
+
+	emailBloomLock.Lock()
+	defer emailBloomLock.Unlock()
+
+	EmailBloomFilter = filter
+	emailBloomCapacity = uint(capacity)
+	emailBloomFalsePositiveRate = falsePositiveRate
+	emailBloomFilled = true
+	emailBloomSyncedAt = syncedAt
+	emailBloomSyncedId = syncedId
+	emailBloomRemovedAt = removedAt
+	emailBloomRemovedId = removedId
+
+	log.Println("Bloom filter loaded from the snapshot synced up to", emailBloomSyncedAt)
+
+	return nil
+}
+
+// Read a string written with its uint16 length in front
+func readSnapshotString(reader io.Reader) (string, error) {
+	var length uint16
+	if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+		return "", err
+	}
+
+	value := make([]byte, length)
+	if _, err := io.ReadFull(reader, value); err != nil {
+		return "", err
+	}
+
+	return string(value), nil
+}
+
+// How SignUp used the filter since the app started
+var emailBloomStats struct {
+	checks         atomic.Uint64 // Emails tested against the filter
+	hits           atomic.Uint64 // Tests that had to fall back to MySQL
+	falsePositives atomic.Uint64 // Fallbacks where MySQL had no such email
+}
+
+// Count a test of the filter, and when it was a hit whether MySQL had the email
+func RecordEmailBloomCheck(hit bool, inDatabase bool) {
+	emailBloomStats.checks.Add(1)
+
+	if hit {
+		emailBloomStats.hits.Add(1)
+
+		if !inDatabase {
+			emailBloomStats.falsePositives.Add(1)
+		}
+	}
 }
 
-// Custom hash function for Bloom Filter (optional)
+// Numbers on how well the filter saves MySQL lookups
+func EmailBloomMetrics() map[string]interface{} {
+	checks := emailBloomStats.checks.Load()
+	hits := emailBloomStats.hits.Load()
+	falsePositives := emailBloomStats.falsePositives.Load()
 
-// func fnvHash(data []byte) uint32 {
-// 	hasher := fnv.New32()
-// 	hasher.Write(data)
-// 	return hasher.Sum32()
-// }
+	var fallbackRate, falsePositiveRate float64
+	if checks > 0 {
+		fallbackRate = float64(hits) / float64(checks)
+		falsePositiveRate = float64(falsePositives) / float64(checks)
+	}
+
+	emailBloomLock.RLock()
+	defer emailBloomLock.RUnlock()
+
+	return map[string]interface{}{
+		"warm":                       emailBloomFilled,
+		"capacity":                   emailBloomCapacity,
+		"emails":                     EmailBloomFilter.Items(),
+		"estimatedFalsePositiveRate": EmailBloomFilter.EstimatedFalsePositiveRate(),
+		"checks":                     checks,
+		"mysqlFallbacks":             hits,
+		"falsePositives":             falsePositives,
+		"mysqlFallbackRate":          fallbackRate,
+		"observedFalsePositiveRate":  falsePositiveRate,
+	}
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"log"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/utils"
+)
+
+// Left in canonicalEmail by migration 027, and while BackfillCanonicalEmails moves a row to its new value
+const unresolvedCanonicalEmailPrefix = "unresolved:"
+
+// Kept in canonicalEmail of accounts whose mailbox already belongs to another account
+const collidingCanonicalEmailPrefix = "collision:"
+
+type staleCanonicalEmail struct {
+	id             string
+	email          string
+	canonicalEmail string
+}
+
+// Recompute canonicalEmail, and lowercase the email, of rows computed under other rules than
+// utils.CanonicalEmailVersion. When several accounts share a mailbox, the verified and then oldest
+// one gets it. The others keep their email and can still login, but are logged and marked with a
+// collision placeholder.
+func BackfillCanonicalEmails() {
+	version := utils.CanonicalEmailVersion()
+
+	rows, err := DB.Query(
+		`
+			SELECT id, email, canonicalEmail FROM User
+			WHERE canonicalEmailVersion <> ?
+			ORDER BY verified DESC, createdAt, id
+		`, version,
+	)
+	if err != nil {
+		log.Printf("Failed to find users with a stale canonical email: %v", err)
+		return
+	}
+
+	var users []staleCanonicalEmail
+
+	for rows.Next() {
+		var user staleCanonicalEmail
+		if err := rows.Scan(&user.id, &user.email, &user.canonicalEmail); err != nil {
+			rows.Close()
+			log.Printf("Failed to read users with a stale canonical email: %v", err)
+			return
+		}
+		users = append(users, user)
+	}
+	rows.Close()
+
+	// Free every value that moves first, so a row earlier in the order is not blocked by the old value of a later one
+	for _, user := range users {
+		if utils.CanonicalEmail(user.email) == user.canonicalEmail {
+			continue
+		}
+
+		_, err := DB.Exec(
+			`
+				UPDATE User SET canonicalEmail = ?
+				WHERE id = ? AND canonicalEmailVersion <> ?
+			`, unresolvedCanonicalEmailPrefix+user.id, user.id, version,
+		)
+		if err != nil {
+			log.Printf("Failed to release the canonical email of user %s: %v", user.id, err)
+		}
+	}
+
+	for _, user := range users {
+		if email := utils.NormalizeEmail(user.email); email != user.email {
+			_, err := DB.Exec(`UPDATE User SET email = ? WHERE id = ?`, email, user.id)
+			if IsDuplicateEntry(err) {
+				log.Printf("Email of user %s clashes with another account once lowercased, kept as %s", user.id, user.email)
+			} else if err != nil {
+				log.Printf("Failed to lowercase the email of user %s: %v", user.id, err)
+			}
+		}
+
+		canonicalEmail := utils.CanonicalEmail(user.email)
+
+		// Another instance starting with the same rules may have done this row already
+		_, err := DB.Exec(
+			`
+				UPDATE User SET canonicalEmail = ?, canonicalEmailVersion = ?
+				WHERE id = ? AND canonicalEmailVersion <> ?
+			`, canonicalEmail, version, user.id, version,
+		)
+		if err == nil {
+			continue
+		}
+		if !IsDuplicateEntry(err) {
+			log.Printf("Failed to set the canonical email of user %s: %v", user.id, err)
+			continue
+		}
+
+		var ownerId string
+		DB.QueryRow(`SELECT id FROM User WHERE canonicalEmail = ?`, canonicalEmail).Scan(&ownerId)
+
+		_, err = DB.Exec(
+			`
+				UPDATE User SET canonicalEmail = ?, canonicalEmailVersion = ?
+				WHERE id = ?
+			`, collidingCanonicalEmailPrefix+user.id, version, user.id,
+		)
+		if err != nil {
+			log.Printf("Failed to mark the canonical email of user %s as colliding: %v", user.id, err)
+			continue
+		}
+
+		log.Printf("User %s (%s) shares the mailbox %s with user %s, which keeps it", user.id, user.email, canonicalEmail, ownerId)
+	}
+
+	if len(users) > 0 {
+		log.Printf("Recomputed the canonical email of %d users for the %s rules", len(users), version)
+	}
+}
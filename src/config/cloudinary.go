@@ -4,21 +4,34 @@ import (
 	"context"
 	"fmt"
 	"mime/multipart"
+	"net/url"
 	"os"
+	"path"
+	"strings"
 
 	cloudinary "github.com/cloudinary/cloudinary-go/v2"
 	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
 )
 
-func Cloudinary(file multipart.File) (string, error) {
+func cloudinaryClient() (*cloudinary.Cloudinary, error) {
 	CLOUD_NAME := os.Getenv("CLOUD_NAME")
 	API_KEY := os.Getenv("API_KEY")
 	API_SECRET := os.Getenv("API_SECRET")
-	FOLDER_NAME := os.Getenv("FOLDER_NAME")
 
 	cld, err := cloudinary.NewFromParams(CLOUD_NAME, API_KEY, API_SECRET)
 	if err != nil {
-		return "", fmt.Errorf("failed to create Cloudinary client: %v", err)
+		return nil, fmt.Errorf("failed to create Cloudinary client: %v", err)
+	}
+
+	return cld, nil
+}
+
+func Cloudinary(file multipart.File) (string, error) {
+	FOLDER_NAME := os.Getenv("FOLDER_NAME")
+
+	cld, err := cloudinaryClient()
+	if err != nil {
+		return "", err
 	}
 
 	// Upload the image
@@ -31,3 +44,62 @@ func Cloudinary(file multipart.File) (string, error) {
 
 	return response.SecureURL, nil
 }
+
+// Delete an image uploaded by Cloudinary(). URLs not hosted in our cloud are ignored.
+func DeleteCloudinaryImage(imageURL string) error {
+	publicID, ok := cloudinaryPublicID(imageURL)
+	if !ok {
+		return nil
+	}
+
+	cld, err := cloudinaryClient()
+	if err != nil {
+		return err
+	}
+
+	_, err = cld.Upload.Destroy(context.Background(), uploader.DestroyParams{
+		PublicID: publicID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete image: %v", err)
+	}
+
+	return nil
+}
+
+// Public ID of an image from its delivery URL, which looks like
+// https://res.cloudinary.com/<cloud>/image/upload/v<version>/<folder>/<name>.<ext>
+func cloudinaryPublicID(imageURL string) (string, bool) {
+	parsed, err := url.Parse(imageURL)
+	if err != nil || parsed.Host != "res.cloudinary.com" {
+		return "", false
+	}
+
+	prefix := "/" + os.Getenv("CLOUD_NAME") + "/image/upload/"
+	if !strings.HasPrefix(parsed.Path, prefix) {
+		return "", false
+	}
+
+	parts := strings.Split(strings.TrimPrefix(parsed.Path, prefix), "/")
+	if len(parts) > 1 && isCloudinaryVersion(parts[0]) {
+		parts = parts[1:]
+	}
+
+	publicID := strings.Join(parts, "/")
+
+	return strings.TrimSuffix(publicID, path.Ext(publicID)), publicID != ""
+}
+
+func isCloudinaryVersion(segment string) bool {
+	if len(segment) < 2 || segment[0] != 'v' {
+		return false
+	}
+
+	for _, c := range segment[1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
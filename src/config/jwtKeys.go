@@ -0,0 +1,238 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/utils"
+)
+
+// How often every instance reloads the key set from the database
+const JWTKeyReloadInterval = 5 * time.Minute
+
+// New keys are published this long before they sign, so every instance and JWKS cache knows them first
+const jwtKeyPublishDelay = 2 * JWTKeyReloadInterval
+
+// Algorithm new tokens are signed with
+func JWTAlgorithm() string {
+	switch algorithm := os.Getenv("JWT_ALGORITHM"); algorithm {
+	case utils.JWTAlgorithmRS256, utils.JWTAlgorithmEdDSA:
+		return algorithm
+	default:
+		return utils.JWTAlgorithmHS256
+	}
+}
+
+// Time a signing key is used before it is replaced
+func jwtKeyRotationPeriod() time.Duration {
+	return time.Duration(utils.GetEnvInt("JWT_KEY_ROTATION_DAYS", 30)) * 24 * time.Hour
+}
+
+// Time a replaced key keeps verifying tokens, never shorter than the tokens it signed live
+func jwtKeyOverlap() time.Duration {
+	overlap := time.Duration(utils.GetEnvInt("JWT_KEY_OVERLAP_MINUTES", 60)) * time.Minute
+
+	minimum := jwtKeyPublishDelay + utils.AccessTokenTTL
+	if overlap < minimum {
+		return minimum
+	}
+
+	return overlap
+}
+
+// Set up the keys tokens are signed with. HS256 uses JWT_SECRET, RS256 and EdDSA keys live in the database.
+func InitJWTKeys() {
+	if JWTAlgorithm() == utils.JWTAlgorithmHS256 {
+		if err := utils.SetJWTSecret(os.Getenv("JWT_SECRET")); err != nil {
+			log.Fatalf("Failed to set up JWT signing: %v", err)
+		}
+		return
+	}
+
+	if err := RotateJWTKeys(); err != nil {
+		log.Fatalf("Failed to rotate JWT keys: %v", err)
+	}
+
+	if err := LoadJWTKeys(); err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+}
+
+// Named lock that lets one instance at a time rotate keys
+const jwtKeyRotationLock = "jwt_key_rotation"
+
+// Seconds an instance waits for another one to finish rotating
+const jwtKeyRotationLockTimeout = 30
+
+// Create a new signing key once the current one is due, and drop keys past their overlap
+func RotateJWTKeys() error {
+	ctx := context.Background()
+
+	// Named locks belong to a session, so every statement runs on one connection
+	conn, err := DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Replicas starting together would otherwise each see no current key and create one
+	var locked sql.NullBool
+
+	err = conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`, jwtKeyRotationLock, jwtKeyRotationLockTimeout).Scan(&locked)
+	if err != nil {
+		return err
+	}
+	if !locked.Bool {
+		return errors.New("timed out waiting for the JWT key rotation lock")
+	}
+	defer conn.ExecContext(ctx, `SELECT RELEASE_LOCK(?)`, jwtKeyRotationLock)
+
+	_, err = conn.ExecContext(ctx,
+		`
+			DELETE FROM JwtKey
+			WHERE retiresAt IS NOT NULL AND retiresAt < NOW()
+		`,
+	)
+	if err != nil {
+		return err
+	}
+
+	algorithm := JWTAlgorithm()
+
+	var current bool
+
+	err = conn.QueryRowContext(ctx,
+		`
+			SELECT COUNT(*) > 0 FROM JwtKey
+			WHERE algorithm = ? AND retiresAt IS NULL AND createdAt > NOW() - INTERVAL ? SECOND
+		`, algorithm, int(jwtKeyRotationPeriod().Seconds()),
+	).Scan(&current)
+	if err != nil || current {
+		return err
+	}
+
+	key, err := utils.GenerateJWTKey(algorithm)
+	if err != nil {
+		return err
+	}
+
+	privateKey, err := key.MarshalPrivateKey()
+	if err != nil {
+		return err
+	}
+
+	encryptedKey, err := utils.EncryptSecret(privateKey)
+	if err != nil {
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Older keys keep signing until the new one is published, then verify what they signed
+	_, err = tx.Exec(
+		`
+			UPDATE JwtKey
+			SET retiresAt = NOW() + INTERVAL ? SECOND
+			WHERE retiresAt IS NULL
+		`, int((jwtKeyPublishDelay + jwtKeyOverlap()).Seconds()),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`
+			INSERT INTO JwtKey (id, algorithm, privateKey)
+			VALUES (?, ?, ?)
+		`, key.ID, key.Algorithm, encryptedKey,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Printf("Created JWT signing key %s", key.ID)
+
+	return nil
+}
+
+// Load every key that still verifies tokens and pick the one to sign with
+func LoadJWTKeys() error {
+	rows, err := DB.Query(
+		`
+			SELECT id, algorithm, privateKey, createdAt <= NOW() - INTERVAL ? SECOND
+			FROM JwtKey
+			WHERE retiresAt IS NULL OR retiresAt > NOW()
+			ORDER BY createdAt DESC
+		`, int(jwtKeyPublishDelay.Seconds()),
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	algorithm := JWTAlgorithm()
+
+	var keys []*utils.JWTKey
+	var signing, newest *utils.JWTKey
+
+	for rows.Next() {
+		var id, keyAlgorithm, encryptedKey string
+		var published bool
+
+		if err := rows.Scan(&id, &keyAlgorithm, &encryptedKey, &published); err != nil {
+			return err
+		}
+
+		privateKey, err := utils.DecryptSecret(encryptedKey)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt JWT key %s: %w", id, err)
+		}
+
+		key, err := utils.ParseJWTKey(id, keyAlgorithm, privateKey)
+		if err != nil {
+			return fmt.Errorf("failed to parse JWT key %s: %w", id, err)
+		}
+
+		keys = append(keys, key)
+
+		if keyAlgorithm != algorithm {
+			continue
+		}
+		if newest == nil {
+			newest = key
+		}
+		if signing == nil && published {
+			signing = key
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// The very first key has nothing to wait for
+	if signing == nil {
+		signing = newest
+	}
+
+	if signing == nil {
+		return errors.New("no JWT key found for " + algorithm)
+	}
+
+	utils.SetJWTKeys(signing, keys)
+
+	return nil
+}
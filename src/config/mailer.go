@@ -8,7 +8,8 @@ import (
 	"github.com/Sahil2k07/Blog-App-Go/src/utils"
 )
 
-func Mailer(toEmail string, otp string) error {
+// Send an HTML email to a single recipient
+func SendMail(toEmail, subject, body string) error {
 
 	mailHost := os.Getenv("MAIL_HOST")
 	mailUser := os.Getenv("MAIL_USER")
@@ -16,11 +17,10 @@ func Mailer(toEmail string, otp string) error {
 
 	auth := smtp.PlainAuth("", mailUser, mailPass, mailHost)
 
-	subject := "Subject: Email Verification\n"
+	subjectHeader := "Subject: " + subject + "\n"
 	contentType := "MIME-version: 1.0;\nContent-Type: text/html; charset=\"UTF-8\";\n\n"
-	body := utils.AuthEmail(otp)
 
-	message := []byte(subject + contentType + body)
+	message := []byte(subjectHeader + contentType + body)
 
 	smtpAddr := mailHost + ":587"
 
@@ -31,3 +31,35 @@ func Mailer(toEmail string, otp string) error {
 
 	return nil
 }
+
+func Mailer(toEmail string, otp string) error {
+	return SendMail(toEmail, "Email Verification", utils.AuthEmail(otp))
+}
+
+func MagicLinkMailer(toEmail string, link string) error {
+	return SendMail(toEmail, "Your Login Link", utils.MagicLinkEmail(link))
+}
+
+func PasswordResetMailer(toEmail string, otp string) error {
+	return SendMail(toEmail, "Password Reset", utils.ResetPasswordEmail(otp))
+}
+
+func ChangeEmailMailer(toEmail string, otp string) error {
+	return SendMail(toEmail, "Confirm your new Email", utils.ChangeEmailOtpEmail(otp))
+}
+
+func EmailChangedMailer(toEmail string, newEmail string) error {
+	return SendMail(toEmail, "Your Email was Changed", utils.EmailChangedEmail(newEmail))
+}
+
+func AccountLockedMailer(toEmail string, lockout string) error {
+	return SendMail(toEmail, "Your Account was Locked", utils.AccountLockedEmail(lockout))
+}
+
+func InviteMailer(toEmail string, code string, expiresAt string) error {
+	return SendMail(toEmail, "You are Invited to Blog App", utils.InviteEmail(code, expiresAt))
+}
+
+func PasswordChangedMailer(toEmail string) error {
+	return SendMail(toEmail, "Your Password was Changed", utils.PasswordChangedEmail())
+}
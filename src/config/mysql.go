@@ -2,10 +2,11 @@ package config
 
 import (
 	"database/sql"
+	"errors"
 	"log"
 	"os"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
 var DB *sql.DB
@@ -34,3 +35,9 @@ func DBDisconnect() {
 	}
 	log.Println("Database connection closed.")
 }
+
+// Whether an insert or update hit a unique key
+func IsDuplicateEntry(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
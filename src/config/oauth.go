@@ -0,0 +1,455 @@
+package config
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// An external login provider. OIDC providers are configured from their issuer's
+// discovery document, GitHub only speaks plain OAuth2 and uses its REST API instead.
+type OAuthProvider struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	Issuer      string // Empty for plain OAuth2 providers
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+	JWKSURL     string
+	APIURL      string // GitHub only
+
+	mu   sync.Mutex
+	keys map[string]interface{} // Signing keys of the issuer, by kid
+}
+
+// User details returned by a provider
+type OAuthIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	FirstName     string
+	LastName      string
+	Picture       string
+}
+
+var OAuthProviders = map[string]*OAuthProvider{}
+
+var oauthHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// Register every provider that has credentials in the environment
+func InitOAuthProviders() {
+	redirectBase := strings.TrimSuffix(os.Getenv("OAUTH_REDIRECT_BASE_URL"), "/")
+
+	redirectURL := func(name string) string {
+		return redirectBase + "/auth/oauth/" + name + "/callback"
+	}
+
+	if clientID := os.Getenv("OAUTH_GOOGLE_CLIENT_ID"); clientID != "" {
+		issuer := os.Getenv("OAUTH_GOOGLE_ISSUER")
+		if issuer == "" {
+			issuer = "https://accounts.google.com"
+		}
+
+		OAuthProviders["google"] = &OAuthProvider{
+			Name:         "google",
+			ClientID:     clientID,
+			ClientSecret: os.Getenv("OAUTH_GOOGLE_CLIENT_SECRET"),
+			RedirectURL:  redirectURL("google"),
+			Scopes:       []string{"openid", "email", "profile"},
+			Issuer:       issuer,
+		}
+	}
+
+	if clientID := os.Getenv("OAUTH_GITHUB_CLIENT_ID"); clientID != "" {
+		baseURL := strings.TrimSuffix(os.Getenv("OAUTH_GITHUB_BASE_URL"), "/")
+		if baseURL == "" {
+			baseURL = "https://github.com"
+		}
+
+		apiURL := strings.TrimSuffix(os.Getenv("OAUTH_GITHUB_API_URL"), "/")
+		if apiURL == "" {
+			apiURL = "https://api.github.com"
+		}
+
+		OAuthProviders["github"] = &OAuthProvider{
+			Name:         "github",
+			ClientID:     clientID,
+			ClientSecret: os.Getenv("OAUTH_GITHUB_CLIENT_SECRET"),
+			RedirectURL:  redirectURL("github"),
+			Scopes:       []string{"read:user", "user:email"},
+			AuthURL:      baseURL + "/login/oauth/authorize",
+			TokenURL:     baseURL + "/login/oauth/access_token",
+			APIURL:       apiURL,
+		}
+	}
+
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		name := os.Getenv("OIDC_PROVIDER_NAME")
+		if name == "" {
+			name = "oidc"
+		}
+
+		OAuthProviders[name] = &OAuthProvider{
+			Name:         name,
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  redirectURL(name),
+			Scopes:       []string{"openid", "email", "profile"},
+			Issuer:       issuer,
+		}
+	}
+
+	for name := range OAuthProviders {
+		log.Println("OAuth provider registered:", name)
+	}
+}
+
+func (p *OAuthProvider) IsOIDC() bool {
+	return p.Issuer != ""
+}
+
+func getJSON(ctx context.Context, endpoint, accessToken string, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	resp, err := oauthHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned status %d", endpoint, resp.StatusCode)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(target)
+}
+
+// Load the endpoints from the issuer's discovery document, once
+func (p *OAuthProvider) discover(ctx context.Context) error {
+	if !p.IsOIDC() {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.AuthURL != "" {
+		return nil
+	}
+
+	var document struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserInfoEndpoint      string `json:"userinfo_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+
+	err := getJSON(ctx, strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration", "", &document)
+	if err != nil {
+		return fmt.Errorf("failed to load OIDC discovery document: %w", err)
+	}
+
+	if document.Issuer != p.Issuer {
+		return fmt.Errorf("OIDC issuer mismatch: expected %s, got %s", p.Issuer, document.Issuer)
+	}
+
+	p.TokenURL = document.TokenEndpoint
+	p.UserInfoURL = document.UserInfoEndpoint
+	p.JWKSURL = document.JWKSURI
+	p.AuthURL = document.AuthorizationEndpoint
+
+	return nil
+}
+
+// URL the user is redirected to for signing in at the provider
+func (p *OAuthProvider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	if err := p.discover(ctx); err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("client_id", p.ClientID)
+	query.Set("redirect_uri", p.RedirectURL)
+	query.Set("response_type", "code")
+	query.Set("scope", strings.Join(p.Scopes, " "))
+	query.Set("state", state)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+
+	if p.IsOIDC() {
+		query.Set("nonce", nonce)
+	}
+
+	return p.AuthURL + "?" + query.Encode(), nil
+}
+
+type oauthToken struct {
+	AccessToken      string `json:"access_token"`
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Exchange the authorization code for the provider's tokens
+func (p *OAuthProvider) exchange(ctx context.Context, code, codeVerifier string) (*oauthToken, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("client_secret", p.ClientSecret)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := oauthHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var token oauthToken
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&token); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	}
+
+	if token.Error != "" {
+		return nil, fmt.Errorf("token exchange failed: %s %s", token.Error, token.ErrorDescription)
+	}
+
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		return nil, fmt.Errorf("token exchange returned status %d", resp.StatusCode)
+	}
+
+	return &token, nil
+}
+
+// Exchange the authorization code and return who signed in. For OIDC the ID token
+// has to carry the nonce sent with AuthCodeURL.
+func (p *OAuthProvider) Identity(ctx context.Context, code, codeVerifier, nonce string) (*OAuthIdentity, error) {
+	if err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	token, err := p.exchange(ctx, code, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.IsOIDC() {
+		return p.oidcIdentity(ctx, token, nonce)
+	}
+
+	return p.githubIdentity(ctx, token)
+}
+
+func (p *OAuthProvider) oidcIdentity(ctx context.Context, token *oauthToken, nonce string) (*OAuthIdentity, error) {
+	if token.IDToken == "" {
+		return nil, errors.New("provider did not return an ID token")
+	}
+
+	claims := jwt.MapClaims{}
+
+	_, err := jwt.ParseWithClaims(token.IDToken, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.signingKey(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+
+	identity := &OAuthIdentity{}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.EmailVerified, _ = claims["email_verified"].(bool)
+	identity.FirstName, _ = claims["given_name"].(string)
+	identity.LastName, _ = claims["family_name"].(string)
+	identity.Picture, _ = claims["picture"].(string)
+
+	if tokenNonce, _ := claims["nonce"].(string); nonce == "" || tokenNonce != nonce {
+		return nil, errors.New("ID token nonce does not match the login")
+	}
+
+	// Some issuers keep the email out of the ID token
+	if identity.Email == "" && p.UserInfoURL != "" {
+		var userInfo struct {
+			Subject       string `json:"sub"`
+			Email         string `json:"email"`
+			EmailVerified bool   `json:"email_verified"`
+			GivenName     string `json:"given_name"`
+			FamilyName    string `json:"family_name"`
+			Picture       string `json:"picture"`
+		}
+
+		if err := getJSON(ctx, p.UserInfoURL, token.AccessToken, &userInfo); err != nil {
+			return nil, err
+		}
+
+		if userInfo.Subject != identity.Subject {
+			return nil, errors.New("userinfo subject does not match the ID token")
+		}
+
+		identity.Email = userInfo.Email
+		identity.EmailVerified = userInfo.EmailVerified
+		if identity.FirstName == "" {
+			identity.FirstName = userInfo.GivenName
+		}
+		if identity.LastName == "" {
+			identity.LastName = userInfo.FamilyName
+		}
+		if identity.Picture == "" {
+			identity.Picture = userInfo.Picture
+		}
+	}
+
+	if identity.Subject == "" {
+		return nil, errors.New("ID token has no subject")
+	}
+
+	return identity, nil
+}
+
+func (p *OAuthProvider) githubIdentity(ctx context.Context, token *oauthToken) (*OAuthIdentity, error) {
+	var user struct {
+		ID        int64  `json:"id"`
+		Login     string `json:"login"`
+		Name      string `json:"name"`
+		AvatarURL string `json:"avatar_url"`
+	}
+
+	if err := getJSON(ctx, p.APIURL+"/user", token.AccessToken, &user); err != nil {
+		return nil, err
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+
+	if err := getJSON(ctx, p.APIURL+"/user/emails", token.AccessToken, &emails); err != nil {
+		return nil, err
+	}
+
+	identity := &OAuthIdentity{
+		Subject: fmt.Sprint(user.ID),
+		Picture: user.AvatarURL,
+	}
+
+	for _, email := range emails {
+		if email.Primary {
+			identity.Email = email.Email
+			identity.EmailVerified = email.Verified
+		}
+	}
+
+	name := strings.Fields(user.Name)
+	if len(name) == 0 {
+		name = []string{user.Login}
+	}
+
+	identity.FirstName = name[0]
+	identity.LastName = strings.Join(name[1:], " ")
+
+	return identity, nil
+}
+
+// Public key of the issuer for the given kid, the key set is reloaded when the kid is unknown
+func (p *OAuthProvider) signingKey(ctx context.Context, kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	var keySet struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+
+	if err := getJSON(ctx, p.JWKSURL, "", &keySet); err != nil {
+		return nil, fmt.Errorf("failed to load JWKS: %w", err)
+	}
+
+	p.keys = make(map[string]interface{})
+
+	decode := func(value string) *big.Int {
+		b, _ := base64.RawURLEncoding.DecodeString(value)
+		return new(big.Int).SetBytes(b)
+	}
+
+	for _, key := range keySet.Keys {
+		switch key.Kty {
+		case "RSA":
+			p.keys[key.Kid] = &rsa.PublicKey{N: decode(key.N), E: int(decode(key.E).Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch key.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			p.keys[key.Kid] = &ecdsa.PublicKey{Curve: curve, X: decode(key.X), Y: decode(key.Y)}
+		}
+	}
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("no signing key found for kid %q", kid)
+}
@@ -0,0 +1,212 @@
+package config
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// What the mock provider remembers about an authorization code
+type mockAuthorization struct {
+	codeChallenge string
+	nonce         string
+}
+
+// Local OIDC provider with discovery, JWKS and a token endpoint that enforces PKCE.
+// claims can change the ID token it hands out, to test the checks on it.
+type mockOIDCProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu     sync.Mutex
+	codes  map[string]mockAuthorization
+	claims func(jwt.MapClaims)
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	mock := &mockOIDCProvider{key: key, codes: map[string]mockAuthorization{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 mock.server.URL,
+			"authorization_endpoint": mock.server.URL + "/authorize",
+			"token_endpoint":         mock.server.URL + "/token",
+			"jwks_uri":               mock.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": "test",
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", mock.token)
+
+	mock.server = httptest.NewServer(mux)
+	t.Cleanup(mock.server.Close)
+
+	return mock
+}
+
+// Approve a login the way the provider's login page would, returning the code
+func (m *mockOIDCProvider) authorize(t *testing.T, authURL string) string {
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("AuthCodeURL() returned an invalid URL: %v", err)
+	}
+
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("AuthCodeURL() did not ask for PKCE: %s", authURL)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	code := "code-" + query.Get("state")
+	m.codes[code] = mockAuthorization{codeChallenge: query.Get("code_challenge"), nonce: query.Get("nonce")}
+
+	return code
+}
+
+func (m *mockOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	m.mu.Lock()
+	authorization, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	claimsOverride := m.claims
+	m.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != authorization.codeChallenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":            m.server.URL,
+		"aud":            r.PostForm.Get("client_id"),
+		"sub":            "subject-1",
+		"exp":            time.Now().Add(time.Minute).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          authorization.nonce,
+		"email":          "jane@example.com",
+		"email_verified": true,
+		"given_name":     "Jane",
+	}
+	if claimsOverride != nil {
+		claimsOverride(claims)
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	idToken.Header["kid"] = "test"
+
+	signed, err := idToken.SignedString(m.key)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"access_token": "access", "id_token": signed})
+}
+
+func (m *mockOIDCProvider) provider() *OAuthProvider {
+	return &OAuthProvider{
+		Name:        "oidc",
+		ClientID:    "blog-app",
+		RedirectURL: "http://localhost:3000/auth/oauth/oidc/callback",
+		Scopes:      []string{"openid", "email", "profile"},
+		Issuer:      m.server.URL,
+	}
+}
+
+// Run a login against the mock provider up to the identity, like OAuthLogin and OAuthCallback do
+func (m *mockOIDCProvider) login(t *testing.T, provider *OAuthProvider, verifier, nonce string) (*OAuthIdentity, error) {
+	ctx := context.Background()
+
+	sum := sha256.Sum256([]byte("verifier"))
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", base64.RawURLEncoding.EncodeToString(sum[:]))
+	if err != nil {
+		t.Fatalf("AuthCodeURL() error = %v", err)
+	}
+
+	return provider.Identity(ctx, m.authorize(t, authURL), verifier, nonce)
+}
+
+func TestOIDCLogin(t *testing.T) {
+	mock := newMockOIDCProvider(t)
+
+	identity, err := mock.login(t, mock.provider(), "verifier", "nonce-1")
+	if err != nil {
+		t.Fatalf("Identity() error = %v", err)
+	}
+
+	if identity.Subject != "subject-1" || identity.Email != "jane@example.com" || !identity.EmailVerified || identity.FirstName != "Jane" {
+		t.Errorf("Identity() = %+v", identity)
+	}
+}
+
+func TestOIDCLoginRejected(t *testing.T) {
+	tests := []struct {
+		name     string
+		verifier string
+		nonce    string
+		claims   func(jwt.MapClaims)
+	}{
+		{"wrong PKCE verifier", "other-verifier", "nonce-1", nil},
+		{"wrong nonce", "verifier", "nonce-2", nil},
+		{"no nonce in the token", "verifier", "nonce-1", func(c jwt.MapClaims) { delete(c, "nonce") }},
+		{"wrong issuer", "verifier", "nonce-1", func(c jwt.MapClaims) { c["iss"] = "https://attacker.example" }},
+		{"wrong audience", "verifier", "nonce-1", func(c jwt.MapClaims) { c["aud"] = "other-client" }},
+		{"expired", "verifier", "nonce-1", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }},
+		{"no expiry", "verifier", "nonce-1", func(c jwt.MapClaims) { delete(c, "exp") }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mock := newMockOIDCProvider(t)
+			mock.claims = test.claims
+
+			if identity, err := mock.login(t, mock.provider(), test.verifier, test.nonce); err == nil {
+				t.Errorf("Identity() = %+v, want an error", identity)
+			}
+		})
+	}
+}
+
+func TestOIDCDiscoveryIssuerMismatch(t *testing.T) {
+	mock := newMockOIDCProvider(t)
+
+	provider := mock.provider()
+	provider.Issuer = mock.server.URL + "/"
+
+	_, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "challenge")
+	if err == nil || !strings.Contains(err.Error(), "issuer mismatch") {
+		t.Errorf("AuthCodeURL() error = %v, want an issuer mismatch", err)
+	}
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Runs queries, either straight on DB or inside a UnitOfWork
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// A transaction with the context of the request, and work to do once it is committed
+type UnitOfWork struct {
+	*sql.Tx
+	ctx         context.Context
+	afterCommit []func()
+}
+
+func (u *UnitOfWork) Context() context.Context {
+	return u.ctx
+}
+
+func (u *UnitOfWork) Exec(query string, args ...any) (sql.Result, error) {
+	return u.Tx.ExecContext(u.ctx, query, args...)
+}
+
+func (u *UnitOfWork) QueryRow(query string, args ...any) *sql.Row {
+	return u.Tx.QueryRowContext(u.ctx, query, args...)
+}
+
+// Run fn after a successful commit, for side effects like emails that must not happen on rollback
+func (u *UnitOfWork) AfterCommit(fn func()) {
+	u.afterCommit = append(u.afterCommit, fn)
+}
+
+// Run fn in a transaction. It commits when fn returns nil and rolls back on an error or a panic.
+func RunInTransaction(ctx context.Context, fn func(uow *UnitOfWork) error) (err error) {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	uow := &UnitOfWork{Tx: tx, ctx: ctx}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(uow); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	for _, fn := range uow.afterCommit {
+		fn()
+	}
+
+	return nil
+}
@@ -0,0 +1,420 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/audit"
+	"github.com/Sahil2k07/Blog-App-Go/src/config"
+	"github.com/Sahil2k07/Blog-App-Go/src/database"
+	"github.com/Sahil2k07/Blog-App-Go/src/dto"
+	"github.com/Sahil2k07/Blog-App-Go/src/middlewares"
+	"github.com/Sahil2k07/Blog-App-Go/src/utils"
+	"github.com/go-playground/validator/v10"
+)
+
+// All Users, for Admins
+func ListUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WrongMethod(w)
+		return
+	}
+
+	const limit = 25
+	defaultOffset := 0
+
+	offset := parseQueryParam(r.URL.Query().Get("offset"), defaultOffset)
+
+	rows, err := config.DB.Query(
+		`
+			SELECT u.id, u.email, u.verified, u.role, u.createdAt, p.id, p.firstName, p.lastName
+			FROM User u
+			JOIN Profile p ON u.id = p.userId
+			ORDER BY u.createdAt DESC
+			LIMIT ? OFFSET ?
+		`, limit, offset,
+	)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to get Users")
+		return
+	}
+	defer rows.Close()
+
+	users := []map[string]interface{}{}
+
+	for rows.Next() {
+		var id, email, role, profileId, firstName, lastName string
+		var verified bool
+		var createdAtBytes []byte
+
+		err := rows.Scan(&id, &email, &verified, &role, &createdAtBytes, &profileId, &firstName, &lastName)
+		if err != nil {
+			utils.InternalServerError(w, "Error parsing User Details")
+			return
+		}
+
+		// Parse createdAt Time
+		createdAt, err := time.Parse("2006-01-02 15:04:05", string(createdAtBytes))
+		if err != nil {
+			utils.InternalServerError(w, "Error parsing User Details")
+			return
+		}
+
+		users = append(users, map[string]interface{}{
+			"id":        id,
+			"email":     email,
+			"verified":  verified,
+			"role":      role,
+			"createdAt": createdAt,
+			"profileId": profileId,
+			"firstName": firstName,
+			"lastName":  lastName,
+		})
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Got All Users Successfully",
+		"data":    users,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Change the role of a User, for Admins
+func UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.WrongMethod(w)
+		return
+	}
+
+	user, ok := r.Context().Value(middlewares.UserContext).(*middlewares.UserAuthDetails)
+	if !ok || user == nil {
+		utils.UnAuthorized(w, "User is not Authenticated")
+		return
+	}
+
+	id := r.PathValue("id")
+	if id == "" {
+		utils.InvalidInput(w, "User id not found")
+		return
+	}
+
+	// Keeps the last Admin from locking everyone out
+	if id == user.Id {
+		utils.InvalidInput(w, "You cannot change your own role")
+		return
+	}
+
+	var req dto.UpdateRoleRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	var oldRole, email string
+
+	err := config.DB.QueryRow(`SELECT role, email FROM User WHERE id = ?`, id).Scan(&oldRole, &email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.InvalidInput(w, "No User with the given ID found")
+			return
+		}
+		utils.InternalServerError(w, "Error finding user")
+		return
+	}
+
+	// Access tokens carry the role, so the User has to login again for the new one to apply.
+	// Personal access tokens read the role from the database on every request.
+	err = config.RunInTransaction(r.Context(), func(uow *config.UnitOfWork) error {
+		_, err := uow.Exec(
+			`
+				UPDATE User
+				SET role = ?
+				WHERE id = ?
+			`, req.Role, id,
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = uow.Exec(
+			`
+				UPDATE Session
+				SET revoked = true
+				WHERE userId = ? AND revoked = false
+			`, id,
+		)
+		return err
+	})
+	if err != nil {
+		utils.InternalServerError(w, "Something went wrong while changing the role")
+		return
+	}
+
+	audit.Record(r, audit.RoleChanged, id, email, audit.Details{"oldRole": oldRole, "newRole": req.Role, "changedBy": user.Id})
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Role changed successfully",
+		"data": map[string]interface{}{
+			"id":   id,
+			"role": req.Role,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Unpublish any Blog, for Editors and Admins
+func UnpublishBlog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.WrongMethod(w)
+		return
+	}
+
+	user, ok := r.Context().Value(middlewares.UserContext).(*middlewares.UserAuthDetails)
+	if !ok || user == nil {
+		utils.UnAuthorized(w, "User is not Authenticated")
+		return
+	}
+
+	id := r.PathValue("id")
+	if id == "" {
+		utils.InvalidInput(w, "Blog id not found")
+		return
+	}
+
+	result, err := config.DB.Exec(
+		`
+			UPDATE Blog
+			SET published = false
+			WHERE id = ?
+		`, id,
+	)
+	if err != nil {
+		utils.InternalServerError(w, "Something went wrong while unpublishing the Blog")
+		return
+	}
+
+	// Check if rows were affected
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		utils.InternalServerError(w, "Error occurred while checking unpublish result")
+		return
+	}
+
+	if rowsAffected == 0 {
+		utils.InvalidInput(w, "No published Blog with the given ID found")
+		return
+	}
+
+	audit.Record(r, audit.BlogUnpublished, user.Id, user.Email, audit.Details{"blogId": id})
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Blog unpublished successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Delete any Blog, for Admins
+func AdminDeleteBlog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.WrongMethod(w)
+		return
+	}
+
+	user, ok := r.Context().Value(middlewares.UserContext).(*middlewares.UserAuthDetails)
+	if !ok || user == nil {
+		utils.UnAuthorized(w, "User is not Authenticated")
+		return
+	}
+
+	id := r.PathValue("id")
+	if id == "" {
+		utils.InvalidInput(w, "Blog id not found")
+		return
+	}
+
+	result, err := config.DB.Exec(
+		`
+			DELETE FROM Blog
+			WHERE id = ?
+		`, id,
+	)
+	if err != nil {
+		utils.InternalServerError(w, "Something went wrong while deleting the Blog")
+		return
+	}
+
+	// Check if rows were affected
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		utils.InternalServerError(w, "Error occurred while checking deletion result")
+		return
+	}
+
+	if rowsAffected == 0 {
+		utils.InvalidInput(w, "No Blog with the given ID found")
+		return
+	}
+
+	audit.Record(r, audit.BlogDeletedByAdmin, user.Id, user.Email, audit.Details{"blogId": id})
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Blog deleted successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// How often SignUp could skip MySQL thanks to the email Bloom filter, for Admins
+func GetBloomMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WrongMethod(w)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Got Bloom filter metrics Successfully",
+		"data":    config.EmailBloomMetrics(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Security audit log, newest first, for Admins. Filters by userId, type and a from/to RFC3339 range
+func ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WrongMethod(w)
+		return
+	}
+
+	const limit = 50
+	defaultOffset := 0
+
+	query := r.URL.Query()
+	offset := parseQueryParam(query.Get("offset"), defaultOffset)
+
+	var conditions []string
+	var args []interface{}
+
+	if userId := query.Get("userId"); userId != "" {
+		conditions = append(conditions, "userId = ?")
+		args = append(args, userId)
+	}
+
+	if eventType := query.Get("type"); eventType != "" {
+		conditions = append(conditions, "eventType = ?")
+		args = append(args, eventType)
+	}
+
+	for _, bound := range []struct{ param, condition string }{
+		{"from", "createdAt >= ?"},
+		{"to", "createdAt < ?"},
+	} {
+		value := query.Get(bound.param)
+		if value == "" {
+			continue
+		}
+
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			utils.InvalidInput(w, "Invalid "+bound.param+" time, expected RFC3339")
+			return
+		}
+
+		conditions = append(conditions, bound.condition)
+		args = append(args, at.UTC().Format("2006-01-02 15:04:05.000000"))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, limit, offset)
+
+	rows, err := config.DB.Query(
+		`
+			SELECT id, eventType, userId, email, ipAddress, userAgent, requestId, details, createdAt
+			FROM AuditEvent
+			`+where+`
+			ORDER BY createdAt DESC
+			LIMIT ? OFFSET ?
+		`, args...,
+	)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to get Audit Events")
+		return
+	}
+	defer rows.Close()
+
+	events := []database.AuditEvent{}
+
+	for rows.Next() {
+		var event database.AuditEvent
+		var userId, email sql.NullString
+		var details, createdAtBytes []byte
+
+		err := rows.Scan(&event.ID, &event.EventType, &userId, &email, &event.IPAddress, &event.UserAgent, &event.RequestID, &details, &createdAtBytes)
+		if err != nil {
+			utils.InternalServerError(w, "Error parsing Audit Event Details")
+			return
+		}
+
+		if userId.Valid {
+			event.UserID = &userId.String
+		}
+		if email.Valid {
+			event.Email = &email.String
+		}
+		if len(details) > 0 {
+			event.Details = json.RawMessage(details)
+		}
+
+		// Parse createdAt Time, stored with microseconds
+		event.CreatedAt, err = time.Parse("2006-01-02 15:04:05.999999", string(createdAtBytes))
+		if err != nil {
+			utils.InternalServerError(w, "Error parsing Audit Event Details")
+			return
+		}
+
+		events = append(events, event)
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Got Audit Events Successfully",
+		"data":    events,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
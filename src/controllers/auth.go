@@ -1,13 +1,13 @@
 package controllers
 
 import (
-	"crypto/rand"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 
+	"github.com/Sahil2k07/Blog-App-Go/src/audit"
 	"github.com/Sahil2k07/Blog-App-Go/src/config"
 	"github.com/Sahil2k07/Blog-App-Go/src/dto"
 	"github.com/Sahil2k07/Blog-App-Go/src/utils"
@@ -36,46 +36,56 @@ func SignUp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if config.CheckEmailInBloom(req.Email) {
-		// Check in Bloom filter first
-		utils.InvalidInput(w, "Email already Registered")
+	req.Email = utils.NormalizeEmail(req.Email)
+
+	if err := utils.ValidatePasswordStrength(req.Password); err != nil {
+		utils.InvalidInput(w, err.Error())
 		return
-	} else {
-		var existingEmail string
-		err := config.DB.QueryRow(`SELECT email FROM User WHERE email = ?`, req.Email).Scan(&existingEmail)
-		if err == nil {
-			utils.InvalidInput(w, "Email already Registered")
-			return
-		}
 	}
 
-	otp := func() string {
-		otp := make([]byte, 3)
+	registrationMode := utils.RegistrationMode()
 
-		_, err := rand.Read(otp)
-		if err != nil {
-			return "123456"
-		}
+	switch {
+	case registrationMode == utils.RegistrationClosed:
+		utils.Forbidden(w, "Registration is closed")
+		return
+	case registrationMode == utils.RegistrationInvite && req.InviteCode == "":
+		utils.Forbidden(w, "An invite code is required to sign up")
+		return
+	}
 
-		return fmt.Sprintf("%06d", int(otp[0])%10*100000+int(otp[1])%10000+int(otp[2])%1000)
-	}()
+	if err := utils.CheckEmailDomain(req.Email); err != nil {
+		utils.InvalidInput(w, err.Error())
+		return
+	}
 
-	_, err = config.DB.Exec(
-		`
-			INSERT INTO Otp (id, email, otp)
-			VALUES (UUID(), ?, ?)
-		`, req.Email, otp,
-	)
-	if err != nil {
-		utils.InternalServerError(w, "Problem generating OTP")
+	if !requireProofOfWork(w, req.Challenge, req.Solution) {
 		return
 	}
 
-	go func(email, otp string) {
-		if err := config.Mailer(email, otp); err != nil {
-			fmt.Printf("Failed to send OTP to %s: %v\n", email, err)
+	// A miss in a warm Bloom filter means the email was free at the last sync, the unique keys catch
+	// anything registered since. A hit may be a false positive or an email no longer used, so only
+	// hits are checked against the database.
+	bloomWarm := config.EmailBloomWarm()
+	bloomHit := config.CheckEmailInBloom(req.Email)
+
+	canonicalEmail := utils.CanonicalEmail(req.Email)
+
+	if !bloomWarm || bloomHit {
+		var existingEmail string
+		err = config.DB.QueryRow(`SELECT email FROM User WHERE email = ? OR canonicalEmail = ?`, req.Email, canonicalEmail).Scan(&existingEmail)
+
+		if bloomWarm {
+			config.RecordEmailBloomCheck(true, err == nil)
+		}
+
+		if err == nil {
+			utils.InvalidInput(w, "Email already Registered")
+			return
 		}
-	}(req.Email, otp)
+	} else {
+		config.RecordEmailBloomCheck(false, false)
+	}
 
 	hashedPassword, err := utils.HashPassword(req.Password)
 	if err != nil {
@@ -84,32 +94,68 @@ func SignUp(w http.ResponseWriter, r *http.Request) {
 	}
 
 	userId := uuid.New().String()
+	imageURL := fmt.Sprintf("https://api.dicebear.com/5.x/initials/svg?seed=%s %s", req.FirstName, req.LastName)
 
-	_, err = config.DB.Exec(
-		`
-			INSERT INTO User (id, email, password)
-			VALUES (?, ?, ?)
-		`, userId, req.Email, hashedPassword)
-	if err != nil {
-		utils.InternalServerError(w, "Failed to create user")
-		return
-	}
+	// The User, its Profile, the Otp and the use of the invite happen together or not at all
+	err = config.RunInTransaction(r.Context(), func(uow *config.UnitOfWork) error {
+		var inviteId string
 
-	imageURL := fmt.Sprintf("https://api.dicebear.com/5.x/initials/svg?seed=%s %s", req.FirstName, req.LastName)
+		if registrationMode == utils.RegistrationInvite {
+			var err error
+			if inviteId, err = consumeInvite(uow.Context(), uow, req.InviteCode, req.Email); err != nil {
+				return err
+			}
+		}
 
-	_, err = config.DB.Exec(
-		`
-			INSERT INTO Profile(id, userId, firstName, lastName, image)
-			VALUES (UUID(), ?, ?, ?, ?)
-		`, userId, req.FirstName, req.LastName, imageURL,
-	)
+		_, err := uow.Exec(
+			`
+				INSERT INTO User (id, email, canonicalEmail, canonicalEmailVersion, password, inviteId)
+				VALUES (?, ?, ?, ?, ?, NULLIF(?, ''))
+			`, userId, req.Email, canonicalEmail, utils.CanonicalEmailVersion(), hashedPassword, inviteId)
+		if err != nil {
+			return err
+		}
+
+		_, err = uow.Exec(
+			`
+				INSERT INTO Profile(id, userId, firstName, lastName, image)
+				VALUES (UUID(), ?, ?, ?, ?)
+			`, userId, req.FirstName, req.LastName, imageURL,
+		)
+		if err != nil {
+			return err
+		}
+
+		otp, err := issueOtp(uow.Context(), uow, req.Email, otpPurposeVerifyEmail)
+		if err != nil {
+			return err
+		}
+
+		uow.AfterCommit(func() {
+			sendEmail(r, "verification", req.Email, func() error {
+				return config.Mailer(req.Email, otp)
+			})
+		})
+
+		return nil
+	})
 	if err != nil {
-		utils.InternalServerError(w, "Failed to create user profile")
+		var cooldownErr *otpCooldownError
+
+		switch {
+		// Registered after the filter last synced
+		case config.IsDuplicateEntry(err):
+			utils.InvalidInput(w, "Email already Registered")
+		case errors.As(err, &cooldownErr):
+			otpError(w, err)
+		case errors.Is(err, errInviteInvalid):
+			utils.Forbidden(w, "Invite code is invalid, used up or expired")
+		default:
+			utils.InternalServerError(w, "Failed to create user")
+		}
 		return
 	}
 
-	config.AddEmailToBloom(req.Email)
-
 	response := map[string]interface{}{
 		"success": true,
 		"message": "User Created Successfully. Please verify with the OTP to continue",
@@ -143,8 +189,9 @@ func VerifyUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.Email = utils.NormalizeEmail(req.Email)
+
 	var verified bool
-	var dbOtp string
 
 	err = config.DB.QueryRow(
 		`
@@ -167,18 +214,8 @@ func VerifyUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = config.DB.QueryRow(
-		`
-			SELECT otp FROM Otp
-			WHERE email = ?
-		`, req.Email,
-	).Scan(&dbOtp)
-	if err != nil {
-
-	}
-
-	if dbOtp != req.Otp {
-		utils.InvalidInput(w, "Wrong OTP")
+	if err := verifyOtp(r, req.Email, otpPurposeVerifyEmail, req.Otp); err != nil {
+		otpError(w, err)
 		return
 	}
 
@@ -194,13 +231,6 @@ func VerifyUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	config.DB.Exec(
-		`
-			DELETE FROM Otp
-			WHERE email = ?	
-		`, req.Email,
-	)
-
 	response := map[string]interface{}{
 		"success": true,
 		"message": "User Verified Successfully, Can Login now",
@@ -233,6 +263,12 @@ func ReSendOtp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.Email = utils.NormalizeEmail(req.Email)
+
+	if !requireProofOfWork(w, req.Challenge, req.Solution) {
+		return
+	}
+
 	// Check if the user exists and is verified
 	var verified bool
 	var email string
@@ -258,37 +294,396 @@ func ReSendOtp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	newOtp := func() string {
-		otp := make([]byte, 3)
+	newOtp, err := issueOtp(r.Context(), config.DB, req.Email, otpPurposeVerifyEmail)
+	if err != nil {
+		otpError(w, err)
+		return
+	}
+
+	sendEmail(r, "verification", req.Email, func() error {
+		return config.Mailer(req.Email, newOtp)
+	})
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "OTP re-sent successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Rotate the Refresh Token and issue a new Access Token
+func RefreshToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WrongMethod(w)
+		return
+	}
+
+	var refreshToken string
+
+	if cookie, err := r.Cookie(refreshTokenCookie); err == nil {
+		refreshToken = cookie.Value
+	} else {
+		var req dto.RefreshTokenRequest
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.InvalidInput(w)
+			return
+		}
+
+		validate := validator.New()
+		if err := validate.Struct(req); err != nil {
+			utils.InvalidInput(w)
+			return
+		}
+
+		refreshToken = req.RefreshToken
+	}
+
+	tokenHash := utils.HashToken(refreshToken)
+
+	var session struct {
+		ID        string
+		Revoked   bool
+		Active    bool
+		UserId    string
+		Email     string
+		Role      string
+		Verified  bool
+		ProfileId string
+	}
+
+	err := config.DB.QueryRow(
+		`
+			SELECT s.id, s.revoked, s.expiresAt > NOW(), u.id, u.email, u.role, u.verified, p.id
+			FROM Session s
+			JOIN User u ON u.id = s.userId
+			JOIN Profile p ON p.userId = u.id
+			WHERE s.refreshTokenHash = ?
+		`, tokenHash,
+	).Scan(&session.ID, &session.Revoked, &session.Active, &session.UserId, &session.Email, &session.Role, &session.Verified, &session.ProfileId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// An already rotated token is being replayed, so the session is likely stolen
+			result, err := config.DB.Exec(
+				`
+					UPDATE Session
+					SET revoked = true
+					WHERE previousRefreshTokenHash = ?
+				`, tokenHash,
+			)
+			if err == nil {
+				if rowsAffected, _ := result.RowsAffected(); rowsAffected > 0 {
+					utils.UnAuthorized(w, "Refresh token reuse detected. Please login again")
+					return
+				}
+			}
+
+			utils.UnAuthorized(w, "Invalid refresh token")
+			return
+		}
+		utils.InternalServerError(w, "Problem in Identifying Session")
+		return
+	}
+
+	if session.Revoked || !session.Active {
+		utils.UnAuthorized(w, "Session has expired. Please login again")
+		return
+	}
+
+	newRefreshToken, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		utils.InternalServerError(w, "Error generating refresh token")
+		return
+	}
+
+	result, err := config.DB.Exec(
+		`
+			UPDATE Session
+			SET previousRefreshTokenHash = refreshTokenHash, refreshTokenHash = ?, expiresAt = DATE_ADD(NOW(), INTERVAL ? SECOND), lastSeenAt = NOW()
+			WHERE id = ? AND refreshTokenHash = ?
+		`, utils.HashToken(newRefreshToken), int(utils.RefreshTokenTTL.Seconds()), session.ID, tokenHash,
+	)
+	if err != nil {
+		utils.InternalServerError(w, "Error rotating refresh token")
+		return
+	}
+
+	// Another request rotated the same token first
+	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected == 0 {
+		utils.UnAuthorized(w, "Invalid refresh token")
+		return
+	}
+
+	accessToken, err := utils.GenerateJWT(session.UserId, session.Email, session.ProfileId, session.ID, session.Role, session.Verified)
+	if err != nil {
+		utils.InternalServerError(w, "Error generating access token")
+		return
+	}
+
+	tokens := &sessionTokens{
+		SessionId:    session.ID,
+		AccessToken:  accessToken,
+		RefreshToken: newRefreshToken,
+	}
+
+	setAuthCookies(w, tokens)
+
+	response := map[string]interface{}{
+		"success":      true,
+		"message":      "Token refreshed successfully",
+		"token":        tokens.AccessToken,
+		"refreshToken": tokens.RefreshToken,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Send a Password Reset code to the User
+func ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WrongMethod(w)
+		return
+	}
+
+	var req dto.ForgotPasswordRequest
 
-		_, err := rand.Read(otp)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	req.Email = utils.NormalizeEmail(req.Email)
+
+	// Same response whether or not the account exists, so emails cannot be enumerated
+	response := map[string]interface{}{
+		"success": true,
+		"message": "If an account exists for this email, a password reset code has been sent",
+	}
+
+	var verified bool
+
+	err := config.DB.QueryRow(
+		`
+			SELECT verified FROM User
+			WHERE email = ?
+		`, req.Email,
+	).Scan(&verified)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		utils.InternalServerError(w, "Problem in Identifying User")
+		return
+	}
+
+	if err == nil && verified {
+		otp, err := issueOtp(r.Context(), config.DB, req.Email, otpPurposeResetPassword)
 		if err != nil {
-			return "123456"
+			otpError(w, err)
+			return
+		}
+
+		sendEmail(r, "password reset", req.Email, func() error {
+			return config.PasswordResetMailer(req.Email, otp)
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Reset the Password using the emailed code
+func ResetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WrongMethod(w)
+		return
+	}
+
+	var req dto.ResetPasswordRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	req.Email = utils.NormalizeEmail(req.Email)
+
+	if err := utils.ValidatePasswordStrength(req.NewPassword); err != nil {
+		utils.InvalidInput(w, err.Error())
+		return
+	}
+
+	if err := verifyOtp(r, req.Email, otpPurposeResetPassword, req.Otp); err != nil {
+		otpError(w, err)
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		utils.InternalServerError(w, "Something went Wrong in our Server")
+		return
+	}
+
+	var userId string
+
+	err = config.DB.QueryRow(
+		`
+			SELECT id FROM User
+			WHERE email = ?
+		`, req.Email,
+	).Scan(&userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.InvalidInput(w, "Invalid or expired reset code")
+			return
 		}
+		utils.InternalServerError(w, "Problem in Identifying User")
+		return
+	}
 
-		return fmt.Sprintf("%06d", int(otp[0])%10*100000+int(otp[1])%10000+int(otp[2])%1000)
-	}()
+	_, err = config.DB.Exec(
+		`
+			UPDATE User
+			SET password = ?
+			WHERE id = ?
+		`, hashedPassword, userId,
+	)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to reset password")
+		return
+	}
 
-	// Update the OTP in the database
+	// Whoever had the old password should not stay logged in
 	_, err = config.DB.Exec(
 		`
-			UPDATE Otp SET otp = ? WHERE email = ?
-		`, newOtp, req.Email,
+			UPDATE Session
+			SET revoked = true
+			WHERE userId = ? AND revoked = false
+		`, userId,
 	)
 	if err != nil {
-		utils.InternalServerError(w, "Failed to update OTP")
+		utils.InternalServerError(w, "Password was reset but existing sessions could not be revoked")
+		return
+	}
+
+	audit.Record(r, audit.PasswordReset, userId, req.Email, nil)
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Password reset successfully, Can Login now",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Restore an account during its deletion grace period
+func RestoreAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WrongMethod(w)
+		return
+	}
+
+	var req dto.RestoreAccountRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	req.Email = utils.NormalizeEmail(req.Email)
+
+	subjects := newLoginSubjects(r, req.Email)
+
+	wait, err := loginWait(subjects)
+	if err != nil {
+		utils.InternalServerError(w, "Error finding user")
+		return
+	}
+	if wait > 0 {
+		loginThrottled(w, wait)
 		return
 	}
 
-	go func(email, otp string) {
-		if err := config.Mailer(email, otp); err != nil {
-			fmt.Printf("Failed to send OTP to %s: %v\n", email, err)
+	var userId, hashedPassword string
+	var restorable sql.NullBool
+
+	err = config.DB.QueryRow(
+		`
+			SELECT id, password, deletionRequestedAt > NOW() - INTERVAL ? DAY
+			FROM User
+			WHERE email = ?
+		`, int(utils.AccountDeletionGracePeriod().Hours()/24), req.Email,
+	).Scan(&userId, &hashedPassword, &restorable)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			checkDummyPassword(req.Password)
+			recordLoginFailure(r, subjects, req.Email, "", "unknown_email")
+			utils.InvalidInput(w, "Invalid email or password")
+			return
 		}
-	}(req.Email, newOtp)
+		utils.InternalServerError(w, "Error finding user")
+		return
+	}
+
+	if !utils.CheckPasswordHash(req.Password, hashedPassword) {
+		recordLoginFailure(r, subjects, req.Email, userId, "wrong_password")
+		utils.InvalidInput(w, "Invalid email or password")
+		return
+	}
+
+	clearLoginFailures(subjects)
+
+	// NULL when the account was never scheduled for deletion
+	if !restorable.Valid {
+		utils.InvalidInput(w, "Account is not scheduled for deletion")
+		return
+	}
+
+	if !restorable.Bool {
+		utils.InvalidInput(w, "The grace period is over, the account can no longer be restored")
+		return
+	}
+
+	_, err = config.DB.Exec(
+		`
+			UPDATE User
+			SET deletionRequestedAt = NULL
+			WHERE id = ?
+		`, userId,
+	)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to restore account")
+		return
+	}
+
+	audit.Record(r, audit.AccountRestored, userId, req.Email, nil)
 
 	response := map[string]interface{}{
 		"success": true,
-		"message": "OTP re-sent successfully",
+		"message": "Account restored successfully, Can Login now",
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -353,12 +353,15 @@ func UpdateBlog(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Editors and Admins may edit anyone's Blog, Authors only their own
+	editsAny := user.Role == middlewares.RoleEditor || user.Role == middlewares.RoleAdmin
+
 	result, err := config.DB.Exec(
 		`
 			UPDATE Blog
 			SET title = ?, content = ?, tags = ?
-			WHERE id = ? AND profileId = ?
-		`, req.Title, req.Content, tagsJSON, id, user.ProfileId,
+			WHERE id = ? AND (profileId = ? OR ?)
+		`, req.Title, req.Content, tagsJSON, id, user.ProfileId, editsAny,
 	)
 	if err != nil {
 		utils.InternalServerError(w, "Someting went Wrong while Updating Blog")
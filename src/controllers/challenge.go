@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/config"
+	"github.com/Sahil2k07/Blog-App-Go/src/utils"
+)
+
+// How long the count of recent challenges is reused before asking MySQL again
+const recentChallengesCacheTTL = 30 * time.Second
+
+var recentChallenges struct {
+	sync.Mutex
+	count     int
+	checkedAt time.Time
+}
+
+// Difficulty of new challenges, based on how many were issued or redeemed recently across all replicas.
+// Bots fetching puzzles they never solve count as much as ones that do.
+func powDifficulty() int {
+	recentChallenges.Lock()
+	defer recentChallenges.Unlock()
+
+	if time.Since(recentChallenges.checkedAt) > recentChallengesCacheTTL {
+		var count int
+
+		err := config.DB.QueryRow(
+			`
+				SELECT COALESCE(GREATEST(SUM(issued), SUM(redeemed)), 0) FROM PowChallengeActivity
+				WHERE bucket > NOW() - INTERVAL ? SECOND
+			`, int(utils.PowActivityWindow.Seconds()),
+		).Scan(&count)
+		if err != nil {
+			// Keep the last known count rather than making signup easier
+			log.Printf("Failed to count recent challenges: %v", err)
+		} else {
+			recentChallenges.count = count
+		}
+
+		recentChallenges.checkedAt = time.Now()
+	}
+
+	return utils.PowDifficulty(recentChallenges.count)
+}
+
+// Count challenges in the bucket of the current minute. Failures only leave the difficulty lower.
+func recordPowActivity(issued, redeemed int) {
+	_, err := config.DB.Exec(
+		`
+			INSERT INTO PowChallengeActivity (bucket, issued, redeemed)
+			VALUES (FROM_UNIXTIME(FLOOR(UNIX_TIMESTAMP() / 60) * 60), ?, ?)
+			ON DUPLICATE KEY UPDATE issued = issued + VALUES(issued), redeemed = redeemed + VALUES(redeemed)
+		`, issued, redeemed,
+	)
+	if err != nil {
+		log.Printf("Failed to record challenge activity: %v", err)
+	}
+}
+
+// Hand out a proof-of-work puzzle to solve before signing up or re-sending an OTP
+func GetChallenge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WrongMethod(w)
+		return
+	}
+
+	token, challenge, err := utils.NewPowChallenge(powDifficulty())
+	if err != nil {
+		utils.InternalServerError(w, "Something went Wrong in our Server")
+		return
+	}
+
+	recordPowActivity(1, 0)
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Find a solution so that SHA-256(challenge + \":\" + solution) starts with difficulty zero bits",
+		"data": map[string]interface{}{
+			"challenge":  token,
+			"algorithm":  "sha256",
+			"difficulty": challenge.Difficulty,
+			"expiresAt":  time.Unix(challenge.ExpiresAt, 0),
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Check a solved challenge and use it up, so one solution cannot be replayed.
+// Writes the error response and returns false when the request has to stop.
+func requireProofOfWork(w http.ResponseWriter, token, solution string) bool {
+	challenge, err := utils.VerifyPowSolution(token, solution)
+	if err != nil {
+		switch {
+		case errors.Is(err, utils.ErrPowChallengeInvalid):
+			utils.InvalidInput(w, "Challenge is invalid or has expired. Please request a new one")
+		case errors.Is(err, utils.ErrPowSolutionInvalid):
+			utils.InvalidInput(w, "Wrong challenge solution")
+		default:
+			utils.InternalServerError(w, "Problem checking the challenge")
+		}
+		return false
+	}
+
+	_, err = config.DB.Exec(
+		`
+			INSERT INTO UsedPowChallenge (nonce, expiresAt)
+			VALUES (?, FROM_UNIXTIME(?))
+		`, challenge.Nonce, challenge.ExpiresAt,
+	)
+	if err != nil {
+		if config.IsDuplicateEntry(err) {
+			utils.InvalidInput(w, "Challenge was already used. Please request a new one")
+			return false
+		}
+		utils.InternalServerError(w, "Problem checking the challenge")
+		return false
+	}
+
+	recordPowActivity(0, 1)
+
+	return true
+}
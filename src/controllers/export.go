@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/config"
+	"github.com/Sahil2k07/Blog-App-Go/src/jobs"
+	"github.com/Sahil2k07/Blog-App-Go/src/middlewares"
+	"github.com/Sahil2k07/Blog-App-Go/src/utils"
+	"github.com/google/uuid"
+)
+
+// How long a download link of an export stays valid
+const dataExportLinkTTL = time.Hour
+
+// Export all of the User's data as a zip archive
+func ExportData(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WrongMethod(w)
+		return
+	}
+
+	user, ok := r.Context().Value(middlewares.UserContext).(*middlewares.UserAuthDetails)
+	if !ok || user == nil {
+		utils.UnAuthorized(w, "User is not Authenticated")
+		return
+	}
+
+	var blogCount int
+
+	err := config.DB.QueryRow(
+		`
+			SELECT COUNT(*) FROM Blog
+			WHERE profileId = ?
+		`, user.ProfileId,
+	).Scan(&blogCount)
+	if err != nil {
+		utils.InternalServerError(w, "Error preparing data export")
+		return
+	}
+
+	// Small accounts are exported right away
+	if blogCount <= utils.GetEnvInt("EXPORT_SYNC_MAX_BLOGS", 100) {
+		var archive bytes.Buffer
+
+		if err := jobs.WriteDataExport(user.Id, &archive); err != nil {
+			utils.InternalServerError(w, "Error building data export")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="blog-app-export.zip"`)
+		w.WriteHeader(http.StatusOK)
+		w.Write(archive.Bytes())
+		return
+	}
+
+	// Large accounts are built in the background, reuse an export that is still running
+	if err := jobs.FailStaleDataExports(user.Id); err != nil {
+		utils.InternalServerError(w, "Error preparing data export")
+		return
+	}
+
+	var exportId string
+
+	err = config.DB.QueryRow(
+		`
+			SELECT id FROM DataExport
+			WHERE userId = ? AND status = 'pending'
+		`, user.Id,
+	).Scan(&exportId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		utils.InternalServerError(w, "Error preparing data export")
+		return
+	}
+
+	if exportId == "" {
+		exportId = uuid.New().String()
+
+		_, err = config.DB.Exec(
+			`
+				INSERT INTO DataExport (id, userId)
+				VALUES (?, ?)
+			`, exportId, user.Id,
+		)
+		if err != nil {
+			utils.InternalServerError(w, "Error preparing data export")
+			return
+		}
+
+		jobs.StartDataExport(exportId, user.Id)
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Your export is being prepared. Check its status to get the download link",
+		"data": map[string]interface{}{
+			"exportId":  exportId,
+			"status":    "pending",
+			"statusUrl": "/user/export/" + exportId,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Status of a background export, with a signed download link once it is ready
+func GetDataExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WrongMethod(w)
+		return
+	}
+
+	user, ok := r.Context().Value(middlewares.UserContext).(*middlewares.UserAuthDetails)
+	if !ok || user == nil {
+		utils.UnAuthorized(w, "User is not Authenticated")
+		return
+	}
+
+	id := r.PathValue("id")
+	if id == "" {
+		utils.InvalidInput(w, "Export id not found")
+		return
+	}
+
+	var status string
+	var expired sql.NullBool
+
+	err := config.DB.QueryRow(
+		`
+			SELECT status, expiresAt < NOW()
+			FROM DataExport
+			WHERE id = ? AND userId = ?
+		`, id, user.Id,
+	).Scan(&status, &expired)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.InvalidInput(w, "No Export with the given ID found")
+			return
+		}
+		utils.InternalServerError(w, "Error finding data export")
+		return
+	}
+
+	data := map[string]interface{}{
+		"exportId": id,
+		"status":   status,
+	}
+
+	if status == "ready" && !expired.Bool {
+		downloadUrl, err := utils.SignURL("/user/export/download/"+id, dataExportLinkTTL)
+		if err != nil {
+			utils.InternalServerError(w, "Error generating download link")
+			return
+		}
+
+		data["downloadUrl"] = downloadUrl
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Got Export status Successfully",
+		"data":    data,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Download a background export through its signed link
+func DownloadDataExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WrongMethod(w)
+		return
+	}
+
+	id := r.PathValue("id")
+
+	if !utils.VerifySignedURL(r.URL.Path, r.URL.Query()) {
+		utils.UnAuthorized(w, "Download link is invalid or has expired")
+		return
+	}
+
+	var filePath string
+
+	err := config.DB.QueryRow(
+		`
+			SELECT filePath FROM DataExport
+			WHERE id = ? AND status = 'ready' AND expiresAt > NOW()
+		`, id,
+	).Scan(&filePath)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.InvalidInput(w, "No Export with the given ID found")
+			return
+		}
+		utils.InternalServerError(w, "Error finding data export")
+		return
+	}
+
+	// Missing when EXPORT_DIR is not shared and another replica built the export
+	file, err := os.Open(filePath)
+	if err != nil {
+		log.Printf("Failed to open data export %s: %v", filePath, err)
+		utils.InternalServerError(w, "Export file is not available")
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="blog-app-export.zip"`)
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, file)
+}
@@ -0,0 +1,260 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/audit"
+	"github.com/Sahil2k07/Blog-App-Go/src/config"
+	"github.com/Sahil2k07/Blog-App-Go/src/database"
+	"github.com/Sahil2k07/Blog-App-Go/src/dto"
+	"github.com/Sahil2k07/Blog-App-Go/src/middlewares"
+	"github.com/Sahil2k07/Blog-App-Go/src/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+var errInviteInvalid = errors.New("invite code is invalid, used up or expired")
+
+// Create or list invite codes, for Admins
+func Invites(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		createInvite(w, r)
+	case http.MethodGet:
+		listInvites(w, r)
+	default:
+		utils.WrongMethod(w)
+	}
+}
+
+// Create an invite code, its value is only shown in this response and in the email
+func createInvite(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.UserContext).(*middlewares.UserAuthDetails)
+	if !ok || user == nil {
+		utils.UnAuthorized(w, "User is not Authenticated")
+		return
+	}
+
+	var req dto.CreateInviteRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	req.Email = utils.NormalizeEmail(req.Email)
+
+	maxUses := req.MaxUses
+	if maxUses == 0 {
+		maxUses = 1
+	}
+
+	ttl := utils.DefaultInviteTTL
+	if req.ExpiresInHours > 0 {
+		ttl = time.Duration(req.ExpiresInHours) * time.Hour
+	}
+
+	code, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		utils.InternalServerError(w, "Something went Wrong in our Server")
+		return
+	}
+
+	id := uuid.New().String()
+	expiresAt := time.Now().Add(ttl)
+
+	_, err = config.DB.Exec(
+		`
+			INSERT INTO Invite (id, codeHash, email, maxUses, createdBy, expiresAt)
+			VALUES (?, ?, NULLIF(?, ''), ?, ?, DATE_ADD(NOW(), INTERVAL ? SECOND))
+		`, id, utils.HashToken(code), req.Email, maxUses, user.Id, int(ttl.Seconds()),
+	)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to create invite")
+		return
+	}
+
+	audit.Record(r, audit.InviteCreated, user.Id, user.Email, audit.Details{"inviteId": id, "maxUses": maxUses, "invitedEmail": req.Email})
+
+	if req.Email != "" {
+		sendEmail(r, "invite", req.Email, func() error {
+			return config.InviteMailer(req.Email, code, expiresAt.Format(time.RFC1123))
+		})
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Invite created. Copy the code now, it will not be shown again",
+		"data": map[string]interface{}{
+			"id":        id,
+			"code":      code,
+			"email":     req.Email,
+			"maxUses":   maxUses,
+			"expiresAt": expiresAt,
+			"emailed":   req.Email != "",
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// List invite codes, without their values
+func listInvites(w http.ResponseWriter, r *http.Request) {
+	const limit = 25
+	defaultOffset := 0
+
+	offset := parseQueryParam(r.URL.Query().Get("offset"), defaultOffset)
+
+	rows, err := config.DB.Query(
+		`
+			SELECT id, email, maxUses, uses, revoked, createdBy, expiresAt, createdAt
+			FROM Invite
+			ORDER BY createdAt DESC
+			LIMIT ? OFFSET ?
+		`, limit, offset,
+	)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to get Invites")
+		return
+	}
+	defer rows.Close()
+
+	invites := []database.Invite{}
+
+	for rows.Next() {
+		var invite database.Invite
+		var email, createdBy sql.NullString
+		var expiresAtBytes, createdAtBytes []byte
+
+		err := rows.Scan(&invite.ID, &email, &invite.MaxUses, &invite.Uses, &invite.Revoked, &createdBy, &expiresAtBytes, &createdAtBytes)
+		if err != nil {
+			utils.InternalServerError(w, "Error parsing Invite Details")
+			return
+		}
+
+		if email.Valid {
+			invite.Email = &email.String
+		}
+		if createdBy.Valid {
+			invite.CreatedBy = &createdBy.String
+		}
+
+		// Parse the Time columns
+		if invite.ExpiresAt, err = time.Parse("2006-01-02 15:04:05", string(expiresAtBytes)); err != nil {
+			utils.InternalServerError(w, "Error parsing Invite Details")
+			return
+		}
+		if invite.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAtBytes)); err != nil {
+			utils.InternalServerError(w, "Error parsing Invite Details")
+			return
+		}
+
+		invites = append(invites, invite)
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Got All Invites Successfully",
+		"data":    invites,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Revoke an invite code so it cannot be used anymore, for Admins
+func RevokeInvite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.WrongMethod(w)
+		return
+	}
+
+	user, ok := r.Context().Value(middlewares.UserContext).(*middlewares.UserAuthDetails)
+	if !ok || user == nil {
+		utils.UnAuthorized(w, "User is not Authenticated")
+		return
+	}
+
+	id := r.PathValue("id")
+	if id == "" {
+		utils.InvalidInput(w, "Invite id not found")
+		return
+	}
+
+	result, err := config.DB.Exec(
+		`
+			UPDATE Invite
+			SET revoked = true
+			WHERE id = ? AND revoked = false
+		`, id,
+	)
+	if err != nil {
+		utils.InternalServerError(w, "Something went wrong while revoking the Invite")
+		return
+	}
+
+	// Check if rows were affected
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		utils.InternalServerError(w, "Error occurred while checking revoke result")
+		return
+	}
+
+	if rowsAffected == 0 {
+		utils.InvalidInput(w, "No active Invite with the given ID found")
+		return
+	}
+
+	audit.Record(r, audit.InviteRevoked, user.Id, user.Email, audit.Details{"inviteId": id})
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Invite revoked successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Use up one use of an invite code for the email, returning the invite id.
+// Meant to run in the signup transaction, so a failed signup gives the use back.
+func consumeInvite(ctx context.Context, q config.Querier, code, email string) (string, error) {
+	var inviteId string
+
+	err := q.QueryRowContext(ctx,
+		`
+			SELECT id FROM Invite
+			WHERE codeHash = ? AND revoked = false AND uses < maxUses AND expiresAt > NOW()
+				AND (email IS NULL OR email = ?)
+			FOR UPDATE
+		`, utils.HashToken(code), email,
+	).Scan(&inviteId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", errInviteInvalid
+		}
+		return "", err
+	}
+
+	_, err = q.ExecContext(ctx, `UPDATE Invite SET uses = uses + 1 WHERE id = ?`, inviteId)
+	if err != nil {
+		return "", err
+	}
+
+	return inviteId, nil
+}
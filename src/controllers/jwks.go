@@ -0,0 +1,21 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/utils"
+)
+
+// Public keys other services use to verify our tokens, in the standard JWKS format
+func GetJWKS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WrongMethod(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(utils.JWKS())
+}
@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/audit"
+	"github.com/Sahil2k07/Blog-App-Go/src/config"
+	"github.com/Sahil2k07/Blog-App-Go/src/utils"
+)
+
+// Counters of failed logins for one attempt, by account and by client
+type loginSubjects struct {
+	Email string
+	IP    string
+}
+
+// Aliases of one mailbox share a counter, hashed so long addresses fit the subject column
+func newLoginSubjects(r *http.Request, email string) loginSubjects {
+	return loginSubjects{
+		Email: "email:" + utils.HashToken(utils.CanonicalEmail(email)),
+		IP:    "ip:" + utils.ClientIP(r),
+	}
+}
+
+// How long the caller has to wait before the next attempt, zero when it may go ahead
+func loginWait(subjects loginSubjects) (time.Duration, error) {
+	rows, err := config.DB.Query(
+		`
+			SELECT subject, failures, TIMESTAMPDIFF(SECOND, lastFailureAt, NOW()),
+				IFNULL(TIMESTAMPDIFF(SECOND, NOW(), lockedUntil), 0)
+			FROM LoginFailure
+			WHERE subject IN (?, ?)
+		`, subjects.Email, subjects.IP,
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var wait time.Duration
+
+	for rows.Next() {
+		var subject string
+		var failures, elapsed, locked int
+
+		if err := rows.Scan(&subject, &failures, &elapsed, &locked); err != nil {
+			return 0, err
+		}
+
+		if time.Duration(locked)*time.Second > wait {
+			wait = time.Duration(locked) * time.Second
+		}
+
+		// Only the account gets the growing delay, an IP is shared by many users
+		if subject == subjects.Email && time.Duration(elapsed)*time.Second < utils.LoginFailureWindow {
+			if backoff := utils.LoginBackoff(failures) - time.Duration(elapsed)*time.Second; backoff > wait {
+				wait = backoff
+			}
+		}
+	}
+
+	return wait, rows.Err()
+}
+
+// Write the response for a login that has to wait, without saying whether the account exists
+func loginThrottled(w http.ResponseWriter, wait time.Duration) {
+	utils.TooManyRequests(w, "Too many failed login attempts. Please try again later", wait)
+}
+
+// Count a failed login and lock the account or IP once it has failed too often.
+// userId is empty when no account has the email.
+func recordLoginFailure(r *http.Request, subjects loginSubjects, email, userId, reason string) {
+	audit.Record(r, audit.LoginFailed, userId, email, audit.Details{"reason": reason})
+
+	for _, subject := range []string{subjects.Email, subjects.IP} {
+		_, err := config.DB.Exec(
+			`
+				INSERT INTO LoginFailure (subject, failures, lastFailureAt)
+				VALUES (?, 1, NOW())
+				ON DUPLICATE KEY UPDATE
+					failures = IF(lastFailureAt < NOW() - INTERVAL ? SECOND, 1, failures + 1),
+					lastFailureAt = NOW()
+			`, subject, int(utils.LoginFailureWindow.Seconds()),
+		)
+		if err != nil {
+			log.Printf("Failed to record login failure for %s: %v", subject, err)
+		}
+	}
+
+	lockout := utils.LoginLockoutDuration()
+
+	lock := func(subject string, threshold int) bool {
+		result, err := config.DB.Exec(
+			`
+				UPDATE LoginFailure
+				SET failures = 0, lockedUntil = NOW() + INTERVAL ? SECOND
+				WHERE subject = ? AND failures >= ?
+			`, int(lockout.Seconds()), subject, threshold,
+		)
+		if err != nil {
+			return false
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		return err == nil && rowsAffected > 0
+	}
+
+	if lock(subjects.IP, utils.LoginIPLockoutThreshold()) {
+		audit.Record(r, audit.AccountLocked, "", "", audit.Details{"subject": "ip", "minutes": int(lockout.Minutes())})
+	}
+
+	// Unknown emails are locked the same way, only real accounts get the email
+	if lock(subjects.Email, utils.LoginLockoutThreshold()) && userId != "" {
+		audit.Record(r, audit.AccountLocked, userId, email, audit.Details{"subject": "account", "minutes": int(lockout.Minutes())})
+
+		sendEmail(r, "account locked", email, func() error {
+			return config.AccountLockedMailer(email, fmt.Sprintf("%d minutes", int(lockout.Minutes())))
+		})
+	}
+}
+
+// Forget the failures of an account after a successful login
+func clearLoginFailures(subjects loginSubjects) {
+	config.DB.Exec(`DELETE FROM LoginFailure WHERE subject = ?`, subjects.Email)
+}
+
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := utils.HashPassword("not-a-real-password")
+	return hash
+})
+
+// Spend the same time as a real password check, so unknown emails do not answer faster
+func checkDummyPassword(password string) {
+	utils.CheckPasswordHash(password, dummyPasswordHash())
+}
@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewLoginSubjectsSharesAliases(t *testing.T) {
+	t.Setenv("EMAIL_PROVIDER_RULES", "true")
+
+	r := httptest.NewRequest("POST", "/api/auth/login", nil)
+
+	subjects := newLoginSubjects(r, "john.doe@gmail.com")
+	alias := newLoginSubjects(r, " John.Doe+news@GoogleMail.com")
+
+	if subjects.Email != alias.Email {
+		t.Errorf("alias subject = %q, want %q", alias.Email, subjects.Email)
+	}
+
+	long := newLoginSubjects(r, strings.Repeat("a", 240)+"@example.com")
+	if len(long.Email) > 120 {
+		t.Errorf("subject is %d characters, longer than the LoginFailure column", len(long.Email))
+	}
+}
@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/config"
+	"github.com/Sahil2k07/Blog-App-Go/src/dto"
+	"github.com/Sahil2k07/Blog-App-Go/src/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+// Email a single-use login link, as an alternative to the password
+func RequestMagicLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WrongMethod(w)
+		return
+	}
+
+	var req dto.MagicLinkRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	req.Email = utils.NormalizeEmail(req.Email)
+
+	// Same response whether or not the account exists, so emails cannot be enumerated
+	response := map[string]interface{}{
+		"success": true,
+		"message": "If an account exists for this email, a login link has been sent",
+	}
+
+	var userId string
+
+	err := config.DB.QueryRow(
+		`
+			SELECT id FROM User
+			WHERE email = ? AND verified = true AND deletionRequestedAt IS NULL
+		`, req.Email,
+	).Scan(&userId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		utils.InternalServerError(w, "Problem in Identifying User")
+		return
+	}
+
+	if err == nil {
+		token, err := utils.GenerateOpaqueToken()
+		if err != nil {
+			utils.InternalServerError(w, "Something went Wrong in our Server")
+			return
+		}
+
+		// A new link replaces any link sent before
+		config.DB.Exec(`DELETE FROM MagicLink WHERE userId = ?`, userId)
+
+		_, err = config.DB.Exec(
+			`
+				INSERT INTO MagicLink (id, userId, tokenHash, expiresAt)
+				VALUES (?, ?, ?, DATE_ADD(NOW(), INTERVAL ? SECOND))
+			`, uuid.New().String(), userId, utils.HashToken(token), int(utils.MagicLinkTTL.Seconds()),
+		)
+		if err != nil {
+			utils.InternalServerError(w, "Problem creating login link")
+			return
+		}
+
+		// The link opens a page, which posts the token to MagicLinkLogin when the User confirms
+		link := utils.MagicLinkPageURL() + "#token=" + url.QueryEscape(token)
+
+		sendEmail(r, "login link", req.Email, func() error {
+			return config.MagicLinkMailer(req.Email, link)
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Landing page of the emailed login link, opening it does not use the link up
+func MagicLinkPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WrongMethod(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, utils.MagicLinkPage())
+}
+
+// Exchange the token of an emailed login link for the same session Login hands out
+func MagicLinkLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WrongMethod(w)
+		return
+	}
+
+	var req dto.MagicLinkLoginRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	var linkId string
+
+	err := config.DB.QueryRow(
+		`
+			SELECT id FROM MagicLink
+			WHERE tokenHash = ? AND usedAt IS NULL AND expiresAt > NOW()
+		`, utils.HashToken(req.Token),
+	).Scan(&linkId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.UnAuthorized(w, "Login link is invalid, expired or was already used")
+			return
+		}
+		utils.InternalServerError(w, "Problem in Identifying Login link")
+		return
+	}
+
+	// Only the request that marks the link as used may login with it
+	result, err := config.DB.Exec(
+		`
+			UPDATE MagicLink
+			SET usedAt = NOW()
+			WHERE id = ? AND usedAt IS NULL
+		`, linkId,
+	)
+	if err != nil {
+		utils.InternalServerError(w, "Problem in Identifying Login link")
+		return
+	}
+
+	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected == 0 {
+		utils.UnAuthorized(w, "Login link is invalid, expired or was already used")
+		return
+	}
+
+	var user struct {
+		ID            string
+		Email         string
+		ProfileId     string
+		Role          string
+		Verified      bool
+		TotpEnabled   bool
+		PendingDelete bool
+	}
+
+	err = config.DB.QueryRow(
+		`
+			SELECT u.id, u.email, u.role, u.verified, u.totpEnabled, u.deletionRequestedAt IS NOT NULL, p.id
+			FROM MagicLink m
+			JOIN User u ON u.id = m.userId
+			JOIN Profile p ON u.id = p.userId
+			WHERE m.id = ?
+		`, linkId,
+	).Scan(&user.ID, &user.Email, &user.Role, &user.Verified, &user.TotpEnabled, &user.PendingDelete, &user.ProfileId)
+	if err != nil {
+		utils.InternalServerError(w, "Error finding user")
+		return
+	}
+
+	if user.PendingDelete {
+		utils.InvalidInput(w, "This account is scheduled for deletion. Restore it to login again")
+		return
+	}
+
+	// The link replaces the password, not the second factor
+	if user.TotpEnabled {
+		writeTwoFactorChallenge(w, user.ID)
+		return
+	}
+
+	tokens, err := createSession(r, "magic_link", user.ID, user.Email, user.ProfileId, user.Role, user.Verified)
+	if err != nil {
+		utils.InternalServerError(w, "Error generating access token")
+		return
+	}
+
+	writeLoginResponse(w, user.ID, user.Email, user.ProfileId, user.Role, user.Verified, tokens)
+}
@@ -0,0 +1,21 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/audit"
+	"github.com/Sahil2k07/Blog-App-Go/src/middlewares"
+)
+
+// Send an email in the background. Failures are logged with the request ID and recorded in the audit log.
+func sendEmail(r *http.Request, kind, toEmail string, send func() error) {
+	requestId := middlewares.GetRequestID(r)
+
+	go func() {
+		if err := send(); err != nil {
+			log.Printf("Failed to send %s email to %s (request %s): %v", kind, toEmail, requestId, err)
+			audit.Record(r, audit.EmailDeliveryFailed, "", toEmail, audit.Details{"email": kind, "error": err.Error()})
+		}
+	}()
+}
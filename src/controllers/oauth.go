@@ -0,0 +1,379 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/config"
+	"github.com/Sahil2k07/Blog-App-Go/src/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	oauthFlowCookie = "oauth_flow"
+	oauthFlowTTL    = 10 * time.Minute
+)
+
+// Kept in an encrypted cookie between the redirect to the provider and the callback
+type oauthFlow struct {
+	Provider     string `json:"provider"`
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"codeVerifier"`
+	ExpiresAt    int64  `json:"expiresAt"`
+}
+
+// Redirect the User to the provider's login page
+func OAuthLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WrongMethod(w)
+		return
+	}
+
+	provider, ok := config.OAuthProviders[r.PathValue("provider")]
+	if !ok {
+		utils.InvalidInput(w, "Unknown login provider")
+		return
+	}
+
+	var flow oauthFlow
+	var err error
+
+	flow.Provider = provider.Name
+	flow.ExpiresAt = time.Now().Add(oauthFlowTTL).Unix()
+
+	if flow.State, err = utils.GenerateOpaqueToken(); err != nil {
+		utils.InternalServerError(w, "Something went Wrong in our Server")
+		return
+	}
+	if flow.Nonce, err = utils.GenerateOpaqueToken(); err != nil {
+		utils.InternalServerError(w, "Something went Wrong in our Server")
+		return
+	}
+	if flow.CodeVerifier, err = utils.GenerateOpaqueToken(); err != nil {
+		utils.InternalServerError(w, "Something went Wrong in our Server")
+		return
+	}
+
+	flowJSON, err := json.Marshal(flow)
+	if err != nil {
+		utils.InternalServerError(w, "Something went Wrong in our Server")
+		return
+	}
+
+	encryptedFlow, err := utils.EncryptSecret(string(flowJSON))
+	if err != nil {
+		utils.InternalServerError(w, "Something went Wrong in our Server")
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), flow.State, flow.Nonce, utils.PKCEChallenge(flow.CodeVerifier))
+	if err != nil {
+		log.Printf("Failed to reach login provider %s: %v", provider.Name, err)
+		utils.InternalServerError(w, "Login provider is not available")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthFlowCookie,
+		Value:    encryptedFlow,
+		Path:     "/auth/oauth",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode, // Has to come back on the redirect from the provider
+		MaxAge:   int(oauthFlowTTL.Seconds()),
+	})
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Page OAuthCallback sends the browser to
+func OAuthResultPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WrongMethod(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, utils.OAuthResultPage())
+}
+
+// Send the browser to the result page, with the outcome in the fragment so it never reaches a server log
+func redirectOAuthResult(w http.ResponseWriter, r *http.Request, result url.Values) {
+	http.Redirect(w, r, utils.OAuthResultPageURL()+"#"+result.Encode(), http.StatusFound)
+}
+
+func redirectOAuthError(w http.ResponseWriter, r *http.Request, message string) {
+	redirectOAuthResult(w, r, url.Values{"error": {message}})
+}
+
+// Finish the provider login and sign the User in. The browser lands here from the provider,
+// so every outcome is a redirect to the result page rather than JSON.
+func OAuthCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WrongMethod(w)
+		return
+	}
+
+	provider, ok := config.OAuthProviders[r.PathValue("provider")]
+	if !ok {
+		redirectOAuthError(w, r, "Unknown login provider")
+		return
+	}
+
+	if providerError := r.URL.Query().Get("error"); providerError != "" {
+		redirectOAuthError(w, r, "Login was cancelled or denied by the provider")
+		return
+	}
+
+	cookie, err := r.Cookie(oauthFlowCookie)
+	if err != nil {
+		redirectOAuthError(w, r, "Login session not found. Please try again")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthFlowCookie,
+		Value:    "",
+		Path:     "/auth/oauth",
+		HttpOnly: true,
+		Secure:   true,
+		MaxAge:   -1,
+	})
+
+	var flow oauthFlow
+
+	flowJSON, err := utils.DecryptSecret(cookie.Value)
+	if err != nil || json.Unmarshal([]byte(flowJSON), &flow) != nil {
+		redirectOAuthError(w, r, "Login session not found. Please try again")
+		return
+	}
+
+	if flow.Provider != provider.Name || flow.State != r.URL.Query().Get("state") || time.Now().Unix() > flow.ExpiresAt {
+		redirectOAuthError(w, r, "Login session is invalid or has expired. Please try again")
+		return
+	}
+
+	identity, err := provider.Identity(r.Context(), r.URL.Query().Get("code"), flow.CodeVerifier, flow.Nonce)
+	if err != nil {
+		log.Printf("Login with %s failed: %v", provider.Name, err)
+		redirectOAuthError(w, r, "Could not verify the login with the provider")
+		return
+	}
+
+	identity.Email = utils.NormalizeEmail(identity.Email)
+
+	if identity.Email == "" || !identity.EmailVerified {
+		redirectOAuthError(w, r, "The provider did not share a verified email address")
+		return
+	}
+
+	userId, err := findOrCreateOAuthUser(provider.Name, identity)
+	if err != nil {
+		if errors.Is(err, errRegistrationClosed) {
+			redirectOAuthError(w, r, "No account found for this login, and new accounts need an invite")
+			return
+		}
+		if errors.Is(err, utils.ErrEmailDomainNotAllowed) || errors.Is(err, utils.ErrDisposableEmail) {
+			redirectOAuthError(w, r, err.Error())
+			return
+		}
+		log.Printf("Failed to find or create the user for a %s login: %v", provider.Name, err)
+		redirectOAuthError(w, r, "Problem signing in with the provider")
+		return
+	}
+
+	var user struct {
+		Email         string
+		ProfileId     string
+		Role          string
+		Verified      bool
+		TotpEnabled   bool
+		PendingDelete bool
+	}
+
+	err = config.DB.QueryRow(
+		`
+			SELECT u.email, u.role, u.verified, u.totpEnabled, u.deletionRequestedAt IS NOT NULL, p.id
+			FROM User u
+			JOIN Profile p ON u.id = p.userId
+			WHERE u.id = ?
+		`, userId,
+	).Scan(&user.Email, &user.Role, &user.Verified, &user.TotpEnabled, &user.PendingDelete, &user.ProfileId)
+	if err != nil {
+		redirectOAuthError(w, r, "Error finding user")
+		return
+	}
+
+	if user.PendingDelete {
+		redirectOAuthError(w, r, "This account is scheduled for deletion. Restore it to login again")
+		return
+	}
+
+	if user.TotpEnabled {
+		challengeToken, err := issueTwoFactorChallenge(userId)
+		if err != nil {
+			redirectOAuthError(w, r, "Error generating challenge token")
+			return
+		}
+
+		redirectOAuthResult(w, r, url.Values{"twoFactorChallenge": {challengeToken}})
+		return
+	}
+
+	tokens, err := createSession(r, "oauth:"+provider.Name, userId, user.Email, user.ProfileId, user.Role, user.Verified)
+	if err != nil {
+		redirectOAuthError(w, r, "Error generating access token")
+		return
+	}
+
+	setAuthCookies(w, tokens)
+	redirectOAuthResult(w, r, url.Values{"status": {"success"}})
+}
+
+var errRegistrationClosed = errors.New("registration is not open")
+
+// Find the account linked to the identity. Accounts are linked by verified email,
+// and a new account with a Profile is created when there is none and registration is open.
+func findOrCreateOAuthUser(provider string, identity *config.OAuthIdentity) (string, error) {
+	var userId string
+
+	err := config.DB.QueryRow(
+		`
+			SELECT userId FROM UserIdentity
+			WHERE provider = ? AND subject = ?
+		`, provider, identity.Subject,
+	).Scan(&userId)
+	if err == nil {
+		return userId, nil
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	// Nobody can login with this password, it only fills the column
+	unusablePassword, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	hashedPassword, err := utils.HashPassword(unusablePassword)
+	if err != nil {
+		return "", err
+	}
+
+	var verified bool
+
+	err = config.DB.QueryRow(
+		`
+			SELECT id, verified FROM User
+			WHERE canonicalEmail = ?
+		`, utils.CanonicalEmail(identity.Email),
+	).Scan(&userId, &verified)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	if userId != "" {
+		if !verified {
+			// Whoever signed up with this email never proved owning it, so their password is dropped
+			_, err := tx.Exec(
+				`
+					UPDATE User
+					SET verified = true, password = ?
+					WHERE id = ?
+				`, hashedPassword, userId,
+			)
+			if err != nil {
+				return "", err
+			}
+
+			if _, err := tx.Exec(`UPDATE Session SET revoked = true WHERE userId = ?`, userId); err != nil {
+				return "", err
+			}
+		}
+	} else {
+		// Invites are only checked by SignUp, so providers cannot be used to get around them
+		if utils.RegistrationMode() != utils.RegistrationOpen {
+			return "", errRegistrationClosed
+		}
+
+		if err := utils.CheckEmailDomain(identity.Email); err != nil {
+			return "", err
+		}
+
+		userId = uuid.New().String()
+
+		_, err := tx.Exec(
+			`
+				INSERT INTO User (id, email, canonicalEmail, canonicalEmailVersion, password, verified)
+				VALUES (?, ?, ?, ?, ?, true)
+			`, userId, identity.Email, utils.CanonicalEmail(identity.Email), utils.CanonicalEmailVersion(), hashedPassword,
+		)
+		if err != nil {
+			return "", err
+		}
+
+		firstName := identity.FirstName
+		if firstName == "" {
+			firstName = strings.Split(identity.Email, "@")[0]
+		}
+
+		imageURL := identity.Picture
+		if imageURL == "" {
+			imageURL = fmt.Sprintf("https://api.dicebear.com/5.x/initials/svg?seed=%s %s", firstName, identity.LastName)
+		}
+
+		_, err = tx.Exec(
+			`
+				INSERT INTO Profile(id, userId, firstName, lastName, image)
+				VALUES (UUID(), ?, ?, ?, ?)
+			`, userId, truncate(firstName, 50), truncate(identity.LastName, 50), truncate(imageURL, 255),
+		)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	_, err = tx.Exec(
+		`
+			INSERT INTO UserIdentity (id, userId, provider, subject, email)
+			VALUES (UUID(), ?, ?, ?, ?)
+		`, userId, provider, identity.Subject, identity.Email,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+
+	return userId, nil
+}
+
+// Cut a string to fit a VARCHAR column
+func truncate(value string, length int) string {
+	runes := []rune(value)
+	if len(runes) > length {
+		return string(runes[:length])
+	}
+
+	return value
+}
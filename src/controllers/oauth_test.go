@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/config"
+	"github.com/Sahil2k07/Blog-App-Go/src/utils"
+)
+
+func oauthFlowCookieValue(t *testing.T, flow oauthFlow) string {
+	flowJSON, _ := json.Marshal(flow)
+
+	encrypted, err := utils.EncryptSecret(string(flowJSON))
+	if err != nil {
+		t.Fatalf("EncryptSecret() error = %v", err)
+	}
+
+	return encrypted
+}
+
+// Callbacks that fail before reaching the provider or the database
+func TestOAuthCallbackRejected(t *testing.T) {
+	t.Setenv("ENCRYPTION_KEY", "test-key")
+	t.Setenv("OAUTH_RESULT_PAGE_URL", "http://app.test/oauth/result")
+
+	config.OAuthProviders["test"] = &config.OAuthProvider{Name: "test", Issuer: "http://unused.test"}
+	t.Cleanup(func() { delete(config.OAuthProviders, "test") })
+
+	valid := oauthFlow{Provider: "test", State: "state-1", Nonce: "nonce-1", CodeVerifier: "verifier", ExpiresAt: time.Now().Add(time.Minute).Unix()}
+
+	expired := valid
+	expired.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+
+	otherProvider := valid
+	otherProvider.Provider = "google"
+
+	tests := []struct {
+		name   string
+		query  string
+		cookie string
+	}{
+		{"no flow cookie", "state=state-1&code=abc", ""},
+		{"tampered flow cookie", "state=state-1&code=abc", "not-encrypted"},
+		{"wrong state", "state=state-2&code=abc", oauthFlowCookieValue(t, valid)},
+		{"missing state", "code=abc", oauthFlowCookieValue(t, valid)},
+		{"expired flow", "state=state-1&code=abc", oauthFlowCookieValue(t, expired)},
+		{"flow of another provider", "state=state-1&code=abc", oauthFlowCookieValue(t, otherProvider)},
+		{"denied at the provider", "error=access_denied&state=state-1", oauthFlowCookieValue(t, valid)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/auth/oauth/test/callback?"+test.query, nil)
+			r.SetPathValue("provider", "test")
+			if test.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: oauthFlowCookie, Value: test.cookie})
+			}
+
+			w := httptest.NewRecorder()
+			OAuthCallback(w, r)
+
+			location := w.Header().Get("Location")
+			if w.Code != http.StatusFound || !strings.HasPrefix(location, "http://app.test/oauth/result#") {
+				t.Fatalf("OAuthCallback() = %d to %q, want a redirect to the result page", w.Code, location)
+			}
+
+			fragment, _ := url.ParseQuery(location[strings.Index(location, "#")+1:])
+			if fragment.Get("error") == "" || fragment.Get("status") != "" {
+				t.Errorf("OAuthCallback() redirected with %q, want an error", fragment.Encode())
+			}
+		})
+	}
+}
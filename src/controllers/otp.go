@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/audit"
+	"github.com/Sahil2k07/Blog-App-Go/src/config"
+	"github.com/Sahil2k07/Blog-App-Go/src/utils"
+)
+
+// What an Otp row may be used for
+const (
+	otpPurposeVerifyEmail   = "verify_email"
+	otpPurposeResetPassword = "reset_password"
+	otpPurposeChangeEmail   = "change_email"
+)
+
+var (
+	errOtpInvalid = errors.New("wrong otp")
+	errOtpExpired = errors.New("otp expired or not found")
+	errOtpLocked  = errors.New("too many wrong attempts")
+)
+
+// Returned by issueOtp when a code was sent too recently
+type otpCooldownError struct {
+	RetryAfter time.Duration
+}
+
+func (e *otpCooldownError) Error() string {
+	return fmt.Sprintf("otp requested too often, retry after %s", e.RetryAfter)
+}
+
+// Create or replace the code for the given email and purpose, on DB or inside a UnitOfWork.
+// Wrong attempts carry over to the new code until OtpTTL after the first one was counted.
+func issueOtp(ctx context.Context, q config.Querier, email, purpose string) (string, error) {
+	cooldown := utils.OtpResendCooldown()
+
+	var elapsed int
+
+	err := q.QueryRowContext(ctx,
+		`
+			SELECT TIMESTAMPDIFF(SECOND, createdAt, NOW()) FROM Otp
+			WHERE email = ? AND purpose = ?
+		`, email, purpose,
+	).Scan(&elapsed)
+	if err == nil && time.Duration(elapsed)*time.Second < cooldown {
+		return "", &otpCooldownError{RetryAfter: cooldown - time.Duration(elapsed)*time.Second}
+	} else if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	otp, err := utils.GenerateOtp()
+	if err != nil {
+		return "", err
+	}
+
+	otpHash, err := utils.HashOtp(email, purpose, otp)
+	if err != nil {
+		return "", err
+	}
+
+	ttl := int(utils.OtpTTL().Seconds())
+
+	// attempts is assigned before attemptsSince, so it still sees the old window
+	_, err = q.ExecContext(ctx,
+		`
+			INSERT INTO Otp (id, email, otpHash, purpose)
+			VALUES (UUID(), ?, ?, ?)
+			ON DUPLICATE KEY UPDATE
+				otpHash = VALUES(otpHash),
+				attempts = IF(attemptsSince > NOW() - INTERVAL ? SECOND, attempts, 0),
+				attemptsSince = IF(attemptsSince > NOW() - INTERVAL ? SECOND, attemptsSince, CURRENT_TIMESTAMP),
+				createdAt = CURRENT_TIMESTAMP
+		`, email, otpHash, purpose, ttl, ttl,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return otp, nil
+}
+
+// Check a code and consume it when it matches, recording the outcome in the audit log
+func verifyOtp(r *http.Request, email, purpose, otp string) error {
+	err := checkOtp(email, purpose, otp)
+	if err == nil {
+		audit.Record(r, audit.OtpVerified, "", email, audit.Details{"purpose": purpose})
+	} else if errors.Is(err, errOtpInvalid) || errors.Is(err, errOtpExpired) || errors.Is(err, errOtpLocked) {
+		audit.Record(r, audit.OtpFailed, "", email, audit.Details{"purpose": purpose, "reason": err.Error()})
+	}
+
+	return err
+}
+
+func checkOtp(email, purpose, otp string) error {
+	// Count the attempt before comparing, so concurrent guesses cannot exceed the limit
+	result, err := config.DB.Exec(
+		`
+			UPDATE Otp
+			SET attempts = attempts + 1
+			WHERE email = ? AND purpose = ? AND attempts < ? AND createdAt > NOW() - INTERVAL ? SECOND
+		`, email, purpose, utils.OtpMaxAttempts(), int(utils.OtpTTL().Seconds()),
+	)
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if rowsAffected == 0 {
+		var attempts int
+
+		err := config.DB.QueryRow(
+			`
+				SELECT attempts FROM Otp
+				WHERE email = ? AND purpose = ? AND createdAt > NOW() - INTERVAL ? SECOND
+			`, email, purpose, int(utils.OtpTTL().Seconds()),
+		).Scan(&attempts)
+		if err == nil && attempts >= utils.OtpMaxAttempts() {
+			return errOtpLocked
+		}
+
+		return errOtpExpired
+	}
+
+	var otpId, dbOtpHash string
+
+	err = config.DB.QueryRow(
+		`
+			SELECT id, otpHash FROM Otp
+			WHERE email = ? AND purpose = ?
+		`, email, purpose,
+	).Scan(&otpId, &dbOtpHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errOtpExpired
+		}
+		return err
+	}
+
+	otpHash, err := utils.HashOtp(email, purpose, otp)
+	if err != nil {
+		return err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(dbOtpHash), []byte(otpHash)) != 1 {
+		return errOtpInvalid
+	}
+
+	// Consume the code in one statement, so only one of two concurrent requests can spend it
+	result, err = config.DB.Exec(
+		`
+			DELETE FROM Otp
+			WHERE id = ? AND otpHash = ? AND createdAt > NOW() - INTERVAL ? SECOND
+		`, otpId, dbOtpHash, int(utils.OtpTTL().Seconds()),
+	)
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if rowsAffected != 1 {
+		return errOtpExpired
+	}
+
+	return nil
+}
+
+// Write the response matching an error from issueOtp or verifyOtp
+func otpError(w http.ResponseWriter, err error) {
+	var cooldownErr *otpCooldownError
+
+	switch {
+	case errors.As(err, &cooldownErr):
+		utils.TooManyRequests(w, "Please wait before requesting another code", cooldownErr.RetryAfter)
+	case errors.Is(err, errOtpInvalid):
+		utils.InvalidInput(w, "Wrong OTP")
+	case errors.Is(err, errOtpExpired):
+		utils.InvalidInput(w, "OTP has expired. Please request a new one")
+	case errors.Is(err, errOtpLocked):
+		utils.InvalidInput(w, "Too many wrong attempts. Please request a new OTP in a few minutes")
+	default:
+		utils.InternalServerError(w, "Problem processing OTP")
+	}
+}
@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/audit"
+	"github.com/Sahil2k07/Blog-App-Go/src/config"
+	"github.com/Sahil2k07/Blog-App-Go/src/database"
+	"github.com/Sahil2k07/Blog-App-Go/src/dto"
+	"github.com/Sahil2k07/Blog-App-Go/src/middlewares"
+	"github.com/Sahil2k07/Blog-App-Go/src/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+// Create or list the User's personal access tokens
+func PersonalAccessTokens(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		createPersonalAccessToken(w, r)
+	case http.MethodGet:
+		listPersonalAccessTokens(w, r)
+	default:
+		utils.WrongMethod(w)
+	}
+}
+
+// Create a personal access token, its value is only shown in this response
+func createPersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.UserContext).(*middlewares.UserAuthDetails)
+	if !ok || user == nil {
+		utils.UnAuthorized(w, "User is not Authenticated")
+		return
+	}
+
+	var req dto.CreatePersonalAccessTokenRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	// Keep the scopes in a stable order without duplicates
+	scopes := []string{}
+	for _, scope := range middlewares.Scopes {
+		if slices.Contains(req.Scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	secret, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		utils.InternalServerError(w, "Something went Wrong in our Server")
+		return
+	}
+
+	token := middlewares.PersonalAccessTokenPrefix + secret
+	tokenPrefix := token[:12]
+	id := uuid.New().String()
+
+	// Computed once and stored as a Unix time, so the response matches the row whatever the DB timezone
+	var expiresAt *time.Time
+	var expiresAtUnix sql.NullInt64
+	if req.ExpiresInDays > 0 {
+		expiry := time.Now().Add(time.Duration(req.ExpiresInDays) * 24 * time.Hour).Truncate(time.Second)
+		expiresAt = &expiry
+		expiresAtUnix = sql.NullInt64{Int64: expiry.Unix(), Valid: true}
+	}
+
+	_, err = config.DB.Exec(
+		`
+			INSERT INTO PersonalAccessToken (id, userId, name, tokenHash, tokenPrefix, scopes, expiresAt)
+			VALUES (?, ?, ?, ?, ?, ?, FROM_UNIXTIME(?))
+		`, id, user.Id, req.Name, utils.HashToken(token), tokenPrefix, strings.Join(scopes, ","), expiresAtUnix,
+	)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to create token")
+		return
+	}
+
+	audit.Record(r, audit.TokenCreated, user.Id, user.Email, audit.Details{"tokenId": id, "scopes": scopes})
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Token created. Copy it now, it will not be shown again",
+		"data": map[string]interface{}{
+			"id":          id,
+			"name":        req.Name,
+			"token":       token,
+			"tokenPrefix": tokenPrefix,
+			"scopes":      scopes,
+			"expiresAt":   expiresAt,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// List the User's personal access tokens, without their values
+func listPersonalAccessTokens(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.UserContext).(*middlewares.UserAuthDetails)
+	if !ok || user == nil {
+		utils.UnAuthorized(w, "User is not Authenticated")
+		return
+	}
+
+	rows, err := config.DB.Query(
+		`
+			SELECT id, name, tokenPrefix, scopes, revoked, expiresAt, lastUsedAt, createdAt
+			FROM PersonalAccessToken
+			WHERE userId = ?
+			ORDER BY createdAt DESC
+		`, user.Id,
+	)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to get Tokens")
+		return
+	}
+	defer rows.Close()
+
+	tokens := []database.PersonalAccessToken{}
+
+	for rows.Next() {
+		var token database.PersonalAccessToken
+		var scopes string
+		var createdAtBytes []byte
+		var expiresAt, lastUsedAt sql.NullString
+
+		err := rows.Scan(&token.ID, &token.Name, &token.TokenPrefix, &scopes, &token.Revoked, &expiresAt, &lastUsedAt, &createdAtBytes)
+		if err != nil {
+			utils.InternalServerError(w, "Error parsing Token Details")
+			return
+		}
+
+		token.UserID = user.Id
+		token.Scopes = middlewares.ParseScopes(scopes)
+
+		// Parse the Time columns
+		token.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAtBytes))
+		if err != nil {
+			utils.InternalServerError(w, "Error parsing Token Details")
+			return
+		}
+
+		if expiresAt.Valid {
+			if parsed, err := time.Parse("2006-01-02 15:04:05", expiresAt.String); err == nil {
+				token.ExpiresAt = &parsed
+			}
+		}
+
+		if lastUsedAt.Valid {
+			if parsed, err := time.Parse("2006-01-02 15:04:05", lastUsedAt.String); err == nil {
+				token.LastUsedAt = &parsed
+			}
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Got All Tokens Successfully",
+		"data":    tokens,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Revoke one of the User's personal access tokens
+func RevokePersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.WrongMethod(w)
+		return
+	}
+
+	user, ok := r.Context().Value(middlewares.UserContext).(*middlewares.UserAuthDetails)
+	if !ok || user == nil {
+		utils.UnAuthorized(w, "User is not Authenticated")
+		return
+	}
+
+	id := r.PathValue("id")
+	if id == "" {
+		utils.InvalidInput(w, "Token id not found")
+		return
+	}
+
+	result, err := config.DB.Exec(
+		`
+			UPDATE PersonalAccessToken
+			SET revoked = true
+			WHERE id = ? AND userId = ? AND revoked = false
+		`, id, user.Id,
+	)
+	if err != nil {
+		utils.InternalServerError(w, "Something went wrong while revoking the Token")
+		return
+	}
+
+	// Check if rows were affected
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		utils.InternalServerError(w, "Error occurred while checking revoke result")
+		return
+	}
+
+	if rowsAffected == 0 {
+		utils.InvalidInput(w, "No active Token with the given ID found")
+		return
+	}
+
+	audit.Record(r, audit.TokenRevoked, user.Id, user.Email, audit.Details{"tokenId": id})
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Token revoked successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
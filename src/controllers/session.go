@@ -0,0 +1,290 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/audit"
+	"github.com/Sahil2k07/Blog-App-Go/src/config"
+	"github.com/Sahil2k07/Blog-App-Go/src/database"
+	"github.com/Sahil2k07/Blog-App-Go/src/middlewares"
+	"github.com/Sahil2k07/Blog-App-Go/src/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	accessTokenCookie  = "auth_token"
+	refreshTokenCookie = "refresh_token"
+)
+
+type sessionTokens struct {
+	SessionId    string
+	AccessToken  string
+	RefreshToken string
+	UserAgent    string
+	IPAddress    string
+}
+
+// Keep the current session and logout everywhere else, on DB or inside a UnitOfWork
+func revokeOtherSessions(ctx context.Context, q config.Querier, userId, sessionId string) error {
+	_, err := q.ExecContext(ctx,
+		`
+			UPDATE Session
+			SET revoked = true
+			WHERE userId = ? AND id <> ? AND revoked = false
+		`, userId, sessionId,
+	)
+
+	return err
+}
+
+// Create a new server side Session for the device of the request and issue its tokens.
+// method names how the User proved who they are, for the audit log.
+func createSession(r *http.Request, method, userId, email, profileId, role string, verified bool) (*sessionTokens, error) {
+	refreshToken, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	sessionId := uuid.New().String()
+	userAgent := truncate(r.UserAgent(), 255)
+	ipAddress := truncate(utils.ClientIP(r), 45)
+
+	_, err = config.DB.Exec(
+		`
+			INSERT INTO Session (id, userId, refreshTokenHash, userAgent, ipAddress, expiresAt)
+			VALUES (?, ?, ?, ?, ?, DATE_ADD(NOW(), INTERVAL ? SECOND))
+		`, sessionId, userId, utils.HashToken(refreshToken), userAgent, ipAddress, int(utils.RefreshTokenTTL.Seconds()),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := utils.GenerateJWT(userId, email, profileId, sessionId, role, verified)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.Record(r, audit.LoginSucceeded, userId, email, audit.Details{"method": method, "sessionId": sessionId})
+
+	return &sessionTokens{
+		SessionId:    sessionId,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		UserAgent:    userAgent,
+		IPAddress:    ipAddress,
+	}, nil
+}
+
+// Set the access token cookie
+func setAccessTokenCookie(w http.ResponseWriter, accessToken string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     accessTokenCookie,
+		Value:    accessToken,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   true,
+		MaxAge:   int(utils.AccessTokenTTL.Seconds()),
+	})
+}
+
+// Set both the access and refresh token cookies
+func setAuthCookies(w http.ResponseWriter, tokens *sessionTokens) {
+	setAccessTokenCookie(w, tokens.AccessToken)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshTokenCookie,
+		Value:    tokens.RefreshToken,
+		Path:     "/auth",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   int(utils.RefreshTokenTTL.Seconds()),
+	})
+}
+
+// Expire both the access and refresh token cookies
+func clearAuthCookies(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     accessTokenCookie,
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   true,
+		MaxAge:   -1,
+	})
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshTokenCookie,
+		Value:    "",
+		Path:     "/auth",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   -1,
+	})
+}
+
+// Set the cookies and write the response of a successful login
+func writeLoginResponse(w http.ResponseWriter, userId, email, profileId, role string, verified bool, tokens *sessionTokens) {
+	setAuthCookies(w, tokens)
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Login successful",
+		"user": map[string]interface{}{
+			"id":        userId,
+			"email":     email,
+			"verified":  verified,
+			"profileId": profileId,
+			"role":      role,
+		},
+		"session": map[string]interface{}{
+			"id":        tokens.SessionId,
+			"userAgent": tokens.UserAgent,
+			"ipAddress": tokens.IPAddress,
+		},
+		"token":        tokens.AccessToken,
+		"refreshToken": tokens.RefreshToken,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// List the devices the User is logged in on
+func ListSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WrongMethod(w)
+		return
+	}
+
+	user, ok := r.Context().Value(middlewares.UserContext).(*middlewares.UserAuthDetails)
+	if !ok || user == nil {
+		utils.UnAuthorized(w, "User is not Authenticated")
+		return
+	}
+
+	rows, err := config.DB.Query(
+		`
+			SELECT id, userAgent, ipAddress, createdAt, lastSeenAt, expiresAt
+			FROM Session
+			WHERE userId = ? AND revoked = false AND expiresAt > NOW()
+			ORDER BY lastSeenAt DESC
+		`, user.Id,
+	)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to get Sessions")
+		return
+	}
+	defer rows.Close()
+
+	sessions := []map[string]interface{}{}
+
+	for rows.Next() {
+		var session database.Session
+		var createdAtBytes, lastSeenAtBytes, expiresAtBytes []byte
+
+		err := rows.Scan(&session.ID, &session.UserAgent, &session.IPAddress, &createdAtBytes, &lastSeenAtBytes, &expiresAtBytes)
+		if err != nil {
+			utils.InternalServerError(w, "Error parsing Session Details")
+			return
+		}
+
+		// Parse the Time columns
+		if session.CreatedAt, err = time.Parse("2006-01-02 15:04:05", string(createdAtBytes)); err != nil {
+			utils.InternalServerError(w, "Error parsing Session Details")
+			return
+		}
+		if session.LastSeenAt, err = time.Parse("2006-01-02 15:04:05", string(lastSeenAtBytes)); err != nil {
+			utils.InternalServerError(w, "Error parsing Session Details")
+			return
+		}
+		if session.ExpiresAt, err = time.Parse("2006-01-02 15:04:05", string(expiresAtBytes)); err != nil {
+			utils.InternalServerError(w, "Error parsing Session Details")
+			return
+		}
+
+		sessions = append(sessions, map[string]interface{}{
+			"id":         session.ID,
+			"userAgent":  session.UserAgent,
+			"ipAddress":  session.IPAddress,
+			"createdAt":  session.CreatedAt,
+			"lastSeenAt": session.LastSeenAt,
+			"expiresAt":  session.ExpiresAt,
+			"current":    session.ID == user.SessionId,
+		})
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Got All Sessions Successfully",
+		"data":    sessions,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Logout one of the User's devices
+func RevokeSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.WrongMethod(w)
+		return
+	}
+
+	user, ok := r.Context().Value(middlewares.UserContext).(*middlewares.UserAuthDetails)
+	if !ok || user == nil {
+		utils.UnAuthorized(w, "User is not Authenticated")
+		return
+	}
+
+	id := r.PathValue("id")
+	if id == "" {
+		utils.InvalidInput(w, "Session id not found")
+		return
+	}
+
+	result, err := config.DB.Exec(
+		`
+			UPDATE Session
+			SET revoked = true
+			WHERE id = ? AND userId = ? AND revoked = false
+		`, id, user.Id,
+	)
+	if err != nil {
+		utils.InternalServerError(w, "Something went wrong while revoking the Session")
+		return
+	}
+
+	// Check if rows were affected
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		utils.InternalServerError(w, "Error occurred while checking revoke result")
+		return
+	}
+
+	if rowsAffected == 0 {
+		utils.InvalidInput(w, "No active Session with the given ID found")
+		return
+	}
+
+	if id == user.SessionId {
+		clearAuthCookies(w)
+	}
+
+	audit.Record(r, audit.SessionRevoked, user.Id, user.Email, audit.Details{"sessionId": id})
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Session revoked successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,605 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/audit"
+	"github.com/Sahil2k07/Blog-App-Go/src/config"
+	"github.com/Sahil2k07/Blog-App-Go/src/dto"
+	"github.com/Sahil2k07/Blog-App-Go/src/middlewares"
+	"github.com/Sahil2k07/Blog-App-Go/src/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+const (
+	twoFactorChallengePurpose = "2fa"
+	recoveryCodeCount         = 10
+)
+
+var errSecondFactorInvalid = errors.New("invalid two-factor code")
+
+// Check a TOTP code for the user and remember its time step so it cannot be replayed
+func checkTotp(userId, code string) error {
+	var encryptedSecret sql.NullString
+
+	err := config.DB.QueryRow(
+		`
+			SELECT totpSecret FROM User
+			WHERE id = ?
+		`, userId,
+	).Scan(&encryptedSecret)
+	if err != nil {
+		return err
+	}
+
+	if !encryptedSecret.Valid {
+		return errSecondFactorInvalid
+	}
+
+	secret, err := utils.DecryptSecret(encryptedSecret.String)
+	if err != nil {
+		return err
+	}
+
+	step, ok := utils.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return errSecondFactorInvalid
+	}
+
+	result, err := config.DB.Exec(
+		`
+			UPDATE User
+			SET totpLastStep = ?
+			WHERE id = ? AND (totpLastStep IS NULL OR totpLastStep < ?)
+		`, step, userId, step,
+	)
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected == 0 {
+		return errSecondFactorInvalid
+	}
+
+	return nil
+}
+
+// Accept either a TOTP code or an unused recovery code
+func checkSecondFactor(userId, code, recoveryCode string) error {
+	if code != "" {
+		return checkTotp(userId, code)
+	}
+
+	result, err := config.DB.Exec(
+		`
+			UPDATE RecoveryCode
+			SET usedAt = NOW()
+			WHERE userId = ? AND codeHash = ? AND usedAt IS NULL
+		`, userId, utils.HashToken(utils.NormalizeRecoveryCode(recoveryCode)),
+	)
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected == 0 {
+		return errSecondFactorInvalid
+	}
+
+	return nil
+}
+
+// Replace every recovery code of the user with a fresh set
+func replaceRecoveryCodes(userId string) ([]string, error) {
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM RecoveryCode WHERE userId = ?`, userId); err != nil {
+		return nil, err
+	}
+
+	for _, code := range codes {
+		_, err := tx.Exec(
+			`
+				INSERT INTO RecoveryCode (id, userId, codeHash)
+				VALUES (?, ?, ?)
+			`, uuid.New().String(), userId, utils.HashToken(utils.NormalizeRecoveryCode(code)),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Store a single-use challenge and return its token, the login finishes at /user/2fa/verify
+func issueTwoFactorChallenge(userId string) (string, error) {
+	challengeId := uuid.New().String()
+
+	_, err := config.DB.Exec(
+		`
+			INSERT INTO TwoFactorChallenge (id, userId, expiresAt)
+			VALUES (?, ?, DATE_ADD(NOW(), INTERVAL ? SECOND))
+		`, challengeId, userId, int(utils.ChallengeTokenTTL.Seconds()),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return utils.GenerateChallengeJWT(userId, challengeId, twoFactorChallengePurpose)
+}
+
+// Respond with a challenge token instead of a session
+func writeTwoFactorChallenge(w http.ResponseWriter, userId string) {
+	challengeToken, err := issueTwoFactorChallenge(userId)
+	if err != nil {
+		utils.InternalServerError(w, "Error generating challenge token")
+		return
+	}
+
+	response := map[string]interface{}{
+		"success":           true,
+		"message":           "Two-factor authentication required",
+		"twoFactorRequired": true,
+		"challengeToken":    challengeToken,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Start 2FA enrolment by generating a TOTP secret
+func EnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WrongMethod(w)
+		return
+	}
+
+	user, ok := r.Context().Value(middlewares.UserContext).(*middlewares.UserAuthDetails)
+	if !ok || user == nil {
+		utils.UnAuthorized(w, "User is not Authenticated")
+		return
+	}
+
+	var totpEnabled bool
+
+	err := config.DB.QueryRow(
+		`
+			SELECT totpEnabled FROM User
+			WHERE id = ?
+		`, user.Id,
+	).Scan(&totpEnabled)
+	if err != nil {
+		utils.InternalServerError(w, "Error finding user")
+		return
+	}
+
+	if totpEnabled {
+		utils.InvalidInput(w, "Two-factor authentication is already enabled")
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		utils.InternalServerError(w, "Error generating two-factor secret")
+		return
+	}
+
+	encryptedSecret, err := utils.EncryptSecret(secret)
+	if err != nil {
+		utils.InternalServerError(w, "Error generating two-factor secret")
+		return
+	}
+
+	_, err = config.DB.Exec(
+		`
+			UPDATE User
+			SET totpSecret = ?, totpLastStep = NULL
+			WHERE id = ?
+		`, encryptedSecret, user.Id,
+	)
+	if err != nil {
+		utils.InternalServerError(w, "Error saving two-factor secret")
+		return
+	}
+
+	issuer := os.Getenv("TOTP_ISSUER")
+	if issuer == "" {
+		issuer = "Blog-App"
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Scan the code with your authenticator app and confirm it to enable two-factor authentication",
+		"data": map[string]interface{}{
+			"secret":     secret,
+			"otpauthUri": utils.TOTPURI(issuer, user.Email, secret),
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Confirm the first TOTP code to enable 2FA
+func ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WrongMethod(w)
+		return
+	}
+
+	user, ok := r.Context().Value(middlewares.UserContext).(*middlewares.UserAuthDetails)
+	if !ok || user == nil {
+		utils.UnAuthorized(w, "User is not Authenticated")
+		return
+	}
+
+	var req dto.TwoFactorCodeRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	var totpEnabled bool
+
+	err := config.DB.QueryRow(
+		`
+			SELECT totpEnabled FROM User
+			WHERE id = ?
+		`, user.Id,
+	).Scan(&totpEnabled)
+	if err != nil {
+		utils.InternalServerError(w, "Error finding user")
+		return
+	}
+
+	if totpEnabled {
+		utils.InvalidInput(w, "Two-factor authentication is already enabled")
+		return
+	}
+
+	if err := checkTotp(user.Id, req.Code); err != nil {
+		if errors.Is(err, errSecondFactorInvalid) {
+			utils.InvalidInput(w, "Invalid two-factor code")
+			return
+		}
+		utils.InternalServerError(w, "Problem verifying two-factor code")
+		return
+	}
+
+	codes, err := replaceRecoveryCodes(user.Id)
+	if err != nil {
+		utils.InternalServerError(w, "Error generating recovery codes")
+		return
+	}
+
+	_, err = config.DB.Exec(
+		`
+			UPDATE User
+			SET totpEnabled = true
+			WHERE id = ?
+		`, user.Id,
+	)
+	if err != nil {
+		utils.InternalServerError(w, "Error enabling two-factor authentication")
+		return
+	}
+
+	audit.Record(r, audit.TwoFactorEnabled, user.Id, user.Email, nil)
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Two-factor authentication enabled. Store the recovery codes somewhere safe, they are only shown once",
+		"data": map[string]interface{}{
+			"recoveryCodes": codes,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Replace the recovery codes, the old ones stop working
+func RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WrongMethod(w)
+		return
+	}
+
+	user, ok := r.Context().Value(middlewares.UserContext).(*middlewares.UserAuthDetails)
+	if !ok || user == nil {
+		utils.UnAuthorized(w, "User is not Authenticated")
+		return
+	}
+
+	var req dto.TwoFactorCodeRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	var totpEnabled bool
+
+	err := config.DB.QueryRow(
+		`
+			SELECT totpEnabled FROM User
+			WHERE id = ?
+		`, user.Id,
+	).Scan(&totpEnabled)
+	if err != nil {
+		utils.InternalServerError(w, "Error finding user")
+		return
+	}
+
+	if !totpEnabled {
+		utils.InvalidInput(w, "Two-factor authentication is not enabled")
+		return
+	}
+
+	if err := checkTotp(user.Id, req.Code); err != nil {
+		if errors.Is(err, errSecondFactorInvalid) {
+			utils.InvalidInput(w, "Invalid two-factor code")
+			return
+		}
+		utils.InternalServerError(w, "Problem verifying two-factor code")
+		return
+	}
+
+	codes, err := replaceRecoveryCodes(user.Id)
+	if err != nil {
+		utils.InternalServerError(w, "Error generating recovery codes")
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Recovery codes regenerated successfully",
+		"data": map[string]interface{}{
+			"recoveryCodes": codes,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Turn 2FA off, needs the password and a second factor
+func DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WrongMethod(w)
+		return
+	}
+
+	user, ok := r.Context().Value(middlewares.UserContext).(*middlewares.UserAuthDetails)
+	if !ok || user == nil {
+		utils.UnAuthorized(w, "User is not Authenticated")
+		return
+	}
+
+	var req dto.DisableTwoFactorRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	var hashedPassword string
+	var totpEnabled bool
+
+	err := config.DB.QueryRow(
+		`
+			SELECT password, totpEnabled FROM User
+			WHERE id = ?
+		`, user.Id,
+	).Scan(&hashedPassword, &totpEnabled)
+	if err != nil {
+		utils.InternalServerError(w, "Error finding user")
+		return
+	}
+
+	if !totpEnabled {
+		utils.InvalidInput(w, "Two-factor authentication is not enabled")
+		return
+	}
+
+	if !utils.CheckPasswordHash(req.Password, hashedPassword) {
+		utils.InvalidInput(w, "Wrong password")
+		return
+	}
+
+	if err := checkSecondFactor(user.Id, req.Code, req.RecoveryCode); err != nil {
+		if errors.Is(err, errSecondFactorInvalid) {
+			utils.InvalidInput(w, "Invalid two-factor code")
+			return
+		}
+		utils.InternalServerError(w, "Problem verifying two-factor code")
+		return
+	}
+
+	_, err = config.DB.Exec(
+		`
+			UPDATE User
+			SET totpEnabled = false, totpSecret = NULL, totpLastStep = NULL
+			WHERE id = ?
+		`, user.Id,
+	)
+	if err != nil {
+		utils.InternalServerError(w, "Error disabling two-factor authentication")
+		return
+	}
+
+	config.DB.Exec(`DELETE FROM RecoveryCode WHERE userId = ?`, user.Id)
+
+	audit.Record(r, audit.TwoFactorDisabled, user.Id, user.Email, nil)
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Two-factor authentication disabled",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Exchange the login challenge and a second factor for a session
+func VerifyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WrongMethod(w)
+		return
+	}
+
+	var req dto.VerifyTwoFactorRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	userId, challengeId, err := utils.ValidateChallengeJWT(req.ChallengeToken, twoFactorChallengePurpose)
+	if err != nil {
+		utils.UnAuthorized(w, "Invalid or expired challenge. Please login again")
+		return
+	}
+
+	var user struct {
+		Email       string
+		ProfileId   string
+		Role        string
+		Verified    bool
+		TotpEnabled bool
+	}
+
+	err = config.DB.QueryRow(
+		`
+			SELECT u.email, u.role, u.verified, u.totpEnabled, p.id
+			FROM User u
+			JOIN Profile p ON u.id = p.userId
+			WHERE u.id = ?
+		`, userId,
+	).Scan(&user.Email, &user.Role, &user.Verified, &user.TotpEnabled, &user.ProfileId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.UnAuthorized(w, "Invalid or expired challenge. Please login again")
+			return
+		}
+		utils.InternalServerError(w, "Error finding user")
+		return
+	}
+
+	if !user.TotpEnabled {
+		utils.UnAuthorized(w, "Invalid or expired challenge. Please login again")
+		return
+	}
+
+	// Wrong second factors count against the account like wrong passwords do
+	subjects := newLoginSubjects(r, user.Email)
+
+	wait, err := loginWait(subjects)
+	if err != nil {
+		utils.InternalServerError(w, "Error finding user")
+		return
+	}
+	if wait > 0 {
+		loginThrottled(w, wait)
+		return
+	}
+
+	// Count the attempt before checking the code, a challenge is burned after too many
+	result, err := config.DB.Exec(
+		`
+			UPDATE TwoFactorChallenge
+			SET attempts = attempts + 1
+			WHERE id = ? AND userId = ? AND attempts < ? AND expiresAt > NOW()
+		`, challengeId, userId, utils.ChallengeMaxAttempts,
+	)
+	if err != nil {
+		utils.InternalServerError(w, "Problem verifying two-factor code")
+		return
+	}
+
+	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected == 0 {
+		utils.UnAuthorized(w, "Invalid or expired challenge. Please login again")
+		return
+	}
+
+	if err := checkSecondFactor(userId, req.Code, req.RecoveryCode); err != nil {
+		if errors.Is(err, errSecondFactorInvalid) {
+			recordLoginFailure(r, subjects, user.Email, userId, "wrong_second_factor")
+			utils.InvalidInput(w, "Invalid two-factor code")
+			return
+		}
+		utils.InternalServerError(w, "Problem verifying two-factor code")
+		return
+	}
+
+	// Burn the challenge, only one request may turn it into a session
+	result, err = config.DB.Exec(`DELETE FROM TwoFactorChallenge WHERE id = ?`, challengeId)
+	if err != nil {
+		utils.InternalServerError(w, "Problem verifying two-factor code")
+		return
+	}
+
+	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected != 1 {
+		utils.UnAuthorized(w, "Invalid or expired challenge. Please login again")
+		return
+	}
+
+	clearLoginFailures(subjects)
+
+	tokens, err := createSession(r, "two_factor", userId, user.Email, user.ProfileId, user.Role, user.Verified)
+	if err != nil {
+		utils.InternalServerError(w, "Error generating access token")
+		return
+	}
+
+	writeLoginResponse(w, userId, user.Email, user.ProfileId, user.Role, user.Verified, tokens)
+}
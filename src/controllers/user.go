@@ -4,9 +4,11 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"log"
 	"net/http"
 	"time"
 
+	"github.com/Sahil2k07/Blog-App-Go/src/audit"
 	"github.com/Sahil2k07/Blog-App-Go/src/config"
 	"github.com/Sahil2k07/Blog-App-Go/src/database"
 	"github.com/Sahil2k07/Blog-App-Go/src/dto"
@@ -99,6 +101,10 @@ func UpdateProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if imageUrl != profile.Image {
+		audit.Record(r, audit.ProfileImageChanged, user.Id, user.Email, audit.Details{"image": imageUrl})
+	}
+
 	response := map[string]interface{}{
 		"success": true,
 		"message": "Profile updated successfully",
@@ -198,21 +204,40 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.Email = utils.NormalizeEmail(req.Email)
+
+	subjects := newLoginSubjects(r, req.Email)
+
+	wait, err := loginWait(subjects)
+	if err != nil {
+		utils.InternalServerError(w, "Error finding user")
+		return
+	}
+	if wait > 0 {
+		loginThrottled(w, wait)
+		return
+	}
+
 	var user struct {
 		ID             string
 		ProfileId      string
 		HashedPassword string
+		Role           string
 		Verified       bool
+		TotpEnabled    bool
+		PendingDelete  bool
 	}
 
 	err = config.DB.QueryRow(`
-		SELECT u.id, u.password, u.verified, p.id AS profileId
+		SELECT u.id, u.password, u.role, u.verified, u.totpEnabled, u.deletionRequestedAt IS NOT NULL, p.id AS profileId
 		FROM User u
 		JOIN Profile p ON u.id = p.userId
 		WHERE u.email = ?
-	`, req.Email).Scan(&user.ID, &user.HashedPassword, &user.Verified, &user.ProfileId)
+	`, req.Email).Scan(&user.ID, &user.HashedPassword, &user.Role, &user.Verified, &user.TotpEnabled, &user.PendingDelete, &user.ProfileId)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
+			checkDummyPassword(req.Password)
+			recordLoginFailure(r, subjects, req.Email, "", "unknown_email")
 			utils.InvalidInput(w, "Invalid email or password")
 			return
 		}
@@ -220,41 +245,565 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Same message for unknown emails and wrong passwords, so accounts cannot be enumerated
+	if !utils.CheckPasswordHash(req.Password, user.HashedPassword) {
+		recordLoginFailure(r, subjects, req.Email, user.ID, "wrong_password")
+		utils.InvalidInput(w, "Invalid email or password")
+		return
+	}
+
+	// With 2FA the failures are only forgotten once the second factor is right too,
+	// otherwise knowing the password would reset the lockout for guessing codes
+	if !user.TotpEnabled {
+		clearLoginFailures(subjects)
+	}
+
+	// Upgrade hashes made with an older algorithm or parameters while the password is at hand
+	if utils.PasswordNeedsRehash(user.HashedPassword) {
+		rehashPassword(user.ID, req.Password, user.HashedPassword)
+	}
+
 	if !user.Verified {
 		utils.InvalidInput(w, "Please verify your email")
 		return
 	}
 
-	if !utils.CheckPasswordHash(req.Password, user.HashedPassword) {
-		utils.InvalidInput(w, "Wrong password")
+	if user.PendingDelete {
+		utils.InvalidInput(w, "This account is scheduled for deletion. Restore it to login again")
 		return
 	}
 
-	token, err := utils.GenerateJWT(user.ID, req.Email, user.ProfileId, user.Verified)
+	// The password alone is not enough, hand out a challenge for the second factor
+	if user.TotpEnabled {
+		writeTwoFactorChallenge(w, user.ID)
+		return
+	}
+
+	tokens, err := createSession(r, "password", user.ID, req.Email, user.ProfileId, user.Role, user.Verified)
 	if err != nil {
 		utils.InternalServerError(w, "Error generating access token")
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "auth_token",
-		Value:    token,
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   true,
-		MaxAge:   3 * 24 * 60 * 60, // 3 days
+	writeLoginResponse(w, user.ID, req.Email, user.ProfileId, user.Role, user.Verified, tokens)
+}
+
+// Replace the stored hash with one from the active hasher, unless the password changed meanwhile
+func rehashPassword(userId, password, oldHash string) {
+	newHash, err := utils.HashPassword(password)
+	if err != nil {
+		log.Printf("Failed to rehash password of %s: %v", userId, err)
+		return
+	}
+
+	_, err = config.DB.Exec(
+		`
+			UPDATE User
+			SET password = ?
+			WHERE id = ? AND password = ?
+		`, newHash, userId, oldHash,
+	)
+	if err != nil {
+		log.Printf("Failed to store rehashed password of %s: %v", userId, err)
+	}
+}
+
+// Logout the User from the current Session
+func Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WrongMethod(w)
+		return
+	}
+
+	user, ok := r.Context().Value(middlewares.UserContext).(*middlewares.UserAuthDetails)
+	if !ok || user == nil {
+		utils.UnAuthorized(w, "User is not Authenticated")
+		return
+	}
+
+	_, err := config.DB.Exec(
+		`
+			UPDATE Session
+			SET revoked = true
+			WHERE id = ? AND userId = ?
+		`, user.SessionId, user.Id,
+	)
+	if err != nil {
+		utils.InternalServerError(w, "Something went wrong while logging out")
+		return
+	}
+
+	clearAuthCookies(w)
+
+	audit.Record(r, audit.Logout, user.Id, user.Email, audit.Details{"sessionId": user.SessionId})
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Logged out successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Logout the User from every Session on every device
+func LogoutAll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WrongMethod(w)
+		return
+	}
+
+	user, ok := r.Context().Value(middlewares.UserContext).(*middlewares.UserAuthDetails)
+	if !ok || user == nil {
+		utils.UnAuthorized(w, "User is not Authenticated")
+		return
+	}
+
+	result, err := config.DB.Exec(
+		`
+			UPDATE Session
+			SET revoked = true
+			WHERE userId = ? AND revoked = false
+		`, user.Id,
+	)
+	if err != nil {
+		utils.InternalServerError(w, "Something went wrong while logging out")
+		return
+	}
+
+	revokedSessions, _ := result.RowsAffected()
+
+	clearAuthCookies(w)
+
+	audit.Record(r, audit.Logout, user.Id, user.Email, audit.Details{"allSessions": true})
+
+	response := map[string]interface{}{
+		"success":         true,
+		"message":         "Logged out from all devices successfully",
+		"revokedSessions": revokedSessions,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Change the Password of the logged in User
+func ChangePassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.WrongMethod(w)
+		return
+	}
+
+	user, ok := r.Context().Value(middlewares.UserContext).(*middlewares.UserAuthDetails)
+	if !ok || user == nil {
+		utils.UnAuthorized(w, "User is not Authenticated")
+		return
+	}
+
+	var req dto.ChangePasswordRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	var hashedPassword string
+
+	err := config.DB.QueryRow(
+		`
+			SELECT password FROM User
+			WHERE id = ?
+		`, user.Id,
+	).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.UnAuthorized(w, "User not found")
+			return
+		}
+		utils.InternalServerError(w, "Error finding user")
+		return
+	}
+
+	if !utils.CheckPasswordHash(req.CurrentPassword, hashedPassword) {
+		utils.InvalidInput(w, "Current password is wrong")
+		return
+	}
+
+	if req.NewPassword == req.CurrentPassword {
+		utils.InvalidInput(w, "New password must be different from the current password")
+		return
+	}
+
+	if err := utils.ValidatePasswordStrength(req.NewPassword); err != nil {
+		utils.InvalidInput(w, err.Error())
+		return
+	}
+
+	newHashedPassword, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		utils.InternalServerError(w, "Something went Wrong in our Server")
+		return
+	}
+
+	// The password only changes together with logging out every other session
+	err = config.RunInTransaction(r.Context(), func(uow *config.UnitOfWork) error {
+		_, err := uow.Exec(
+			`
+				UPDATE User
+				SET password = ?
+				WHERE id = ?
+			`, newHashedPassword, user.Id,
+		)
+		if err != nil {
+			return err
+		}
+
+		return revokeOtherSessions(uow.Context(), uow, user.Id, user.SessionId)
+	})
+	if err != nil {
+		utils.InternalServerError(w, "Failed to change password")
+		return
+	}
+
+	sendEmail(r, "password changed", user.Email, func() error {
+		return config.PasswordChangedMailer(user.Email)
 	})
 
+	audit.Record(r, audit.PasswordChanged, user.Id, user.Email, nil)
+
 	response := map[string]interface{}{
 		"success": true,
-		"message": "Login successful",
+		"message": "Password changed successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Start moving the account to a new email, which has to be verified first
+func ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.WrongMethod(w)
+		return
+	}
+
+	user, ok := r.Context().Value(middlewares.UserContext).(*middlewares.UserAuthDetails)
+	if !ok || user == nil {
+		utils.UnAuthorized(w, "User is not Authenticated")
+		return
+	}
+
+	var req dto.ChangeEmailRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	req.NewEmail = utils.NormalizeEmail(req.NewEmail)
+
+	var hashedPassword string
+
+	err := config.DB.QueryRow(
+		`
+			SELECT password FROM User
+			WHERE id = ?
+		`, user.Id,
+	).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.UnAuthorized(w, "User not found")
+			return
+		}
+		utils.InternalServerError(w, "Error finding user")
+		return
+	}
+
+	if !utils.CheckPasswordHash(req.Password, hashedPassword) {
+		utils.InvalidInput(w, "Password is wrong")
+		return
+	}
+
+	if req.NewEmail == user.Email {
+		utils.InvalidInput(w, "New email must be different from the current email")
+		return
+	}
+
+	if err := utils.CheckEmailDomain(req.NewEmail); err != nil {
+		utils.InvalidInput(w, err.Error())
+		return
+	}
+
+	var existingEmail string
+
+	err = config.DB.QueryRow(
+		`
+			SELECT email FROM User
+			WHERE (email = ? OR canonicalEmail = ?) AND id <> ?
+		`, req.NewEmail, utils.CanonicalEmail(req.NewEmail), user.Id,
+	).Scan(&existingEmail)
+	if err == nil {
+		utils.InvalidInput(w, "Email already Registered")
+		return
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		utils.InternalServerError(w, "Error checking email")
+		return
+	}
+
+	otp, err := issueOtp(r.Context(), config.DB, req.NewEmail, otpPurposeChangeEmail)
+	if err != nil {
+		otpError(w, err)
+		return
+	}
+
+	_, err = config.DB.Exec(
+		`
+			UPDATE User
+			SET pendingEmail = ?
+			WHERE id = ?
+		`, req.NewEmail, user.Id,
+	)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to change email")
+		return
+	}
+
+	sendEmail(r, "email change", req.NewEmail, func() error {
+		return config.ChangeEmailMailer(req.NewEmail, otp)
+	})
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "OTP sent to the new email. Confirm it to finish the change",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+var errNoEmailChange = errors.New("no email change was requested")
+
+// Verify the OTP sent to the new email and swap it in
+func ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WrongMethod(w)
+		return
+	}
+
+	user, ok := r.Context().Value(middlewares.UserContext).(*middlewares.UserAuthDetails)
+	if !ok || user == nil {
+		utils.UnAuthorized(w, "User is not Authenticated")
+		return
+	}
+
+	var req dto.ConfirmEmailChangeRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	var oldEmail string
+	var pendingEmail sql.NullString
+
+	err := config.DB.QueryRow(
+		`
+			SELECT email, pendingEmail FROM User
+			WHERE id = ?
+		`, user.Id,
+	).Scan(&oldEmail, &pendingEmail)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.UnAuthorized(w, "User not found")
+			return
+		}
+		utils.InternalServerError(w, "Error finding user")
+		return
+	}
+
+	if !pendingEmail.Valid {
+		utils.InvalidInput(w, "No email change was requested")
+		return
+	}
+
+	if err := verifyOtp(r, pendingEmail.String, otpPurposeChangeEmail, req.Otp); err != nil {
+		otpError(w, err)
+		return
+	}
+
+	// The email only changes together with logging out every other session
+	err = config.RunInTransaction(r.Context(), func(uow *config.UnitOfWork) error {
+		if err := config.RemoveEmailFromBloom(uow.Context(), uow, user.Id); err != nil {
+			return err
+		}
+
+		// Only swap if nobody changed the pending email in the meantime
+		result, err := uow.Exec(
+			`
+				UPDATE User
+				SET email = pendingEmail, canonicalEmail = ?, canonicalEmailVersion = ?, pendingEmail = NULL, verified = true,
+					emailSetAt = CURRENT_TIMESTAMP(6)
+				WHERE id = ? AND pendingEmail = ?
+			`, utils.CanonicalEmail(pendingEmail.String), utils.CanonicalEmailVersion(), user.Id, pendingEmail.String,
+		)
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if rowsAffected == 0 {
+			return errNoEmailChange
+		}
+
+		return revokeOtherSessions(uow.Context(), uow, user.Id, user.SessionId)
+	})
+	if err != nil {
+		switch {
+		// The unique keys on the email columns catch an account registered with it since the request
+		case config.IsDuplicateEntry(err):
+			utils.InvalidInput(w, "Email already Registered")
+		case errors.Is(err, errNoEmailChange):
+			utils.InvalidInput(w, "No email change was requested")
+		default:
+			utils.InternalServerError(w, "Failed to change email")
+		}
+		return
+	}
+
+	// The email is part of the token claims, so the current one is stale now
+	accessToken, err := utils.GenerateJWT(user.Id, pendingEmail.String, user.ProfileId, user.SessionId, user.Role, true)
+	if err != nil {
+		utils.InternalServerError(w, "Error generating access token")
+		return
+	}
+
+	setAccessTokenCookie(w, accessToken)
+
+	sendEmail(r, "email changed", oldEmail, func() error {
+		return config.EmailChangedMailer(oldEmail, pendingEmail.String)
+	})
+
+	audit.Record(r, audit.EmailChanged, user.Id, pendingEmail.String, audit.Details{"oldEmail": oldEmail})
+
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Email changed successfully",
 		"user": map[string]interface{}{
-			"id":        user.ID,
-			"email":     req.Email,
-			"verified":  user.Verified,
+			"id":        user.Id,
+			"email":     pendingEmail.String,
+			"verified":  true,
 			"profileId": user.ProfileId,
+			"role":      user.Role,
 		},
-		"token": token,
+		"token": accessToken,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Schedule the User's account for deletion
+func DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.WrongMethod(w)
+		return
+	}
+
+	user, ok := r.Context().Value(middlewares.UserContext).(*middlewares.UserAuthDetails)
+	if !ok || user == nil {
+		utils.UnAuthorized(w, "User is not Authenticated")
+		return
+	}
+
+	var req dto.DeleteAccountRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		utils.InvalidInput(w)
+		return
+	}
+
+	var hashedPassword string
+
+	err := config.DB.QueryRow(
+		`
+			SELECT password FROM User
+			WHERE id = ?
+		`, user.Id,
+	).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.UnAuthorized(w, "User not found")
+			return
+		}
+		utils.InternalServerError(w, "Error finding user")
+		return
+	}
+
+	if !utils.CheckPasswordHash(req.Password, hashedPassword) {
+		utils.InvalidInput(w, "Wrong password")
+		return
+	}
+
+	_, err = config.DB.Exec(
+		`
+			UPDATE User
+			SET deletionRequestedAt = NOW()
+			WHERE id = ?
+		`, user.Id,
+	)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to delete account")
+		return
+	}
+
+	config.DB.Exec(
+		`
+			UPDATE Session
+			SET revoked = true
+			WHERE userId = ? AND revoked = false
+		`, user.Id,
+	)
+
+	clearAuthCookies(w)
+
+	gracePeriod := utils.AccountDeletionGracePeriod()
+
+	audit.Record(r, audit.AccountDeletionRequested, user.Id, user.Email, nil)
+
+	response := map[string]interface{}{
+		"success":     true,
+		"message":     "Account scheduled for deletion. It can be restored until it is permanently deleted",
+		"deletableAt": time.Now().Add(gracePeriod),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,20 @@
+-- +goose Up
+
+CREATE TABLE Session (
+    id CHAR(36) PRIMARY KEY,
+    userId CHAR(36) NOT NULL,
+    refreshTokenHash CHAR(64) UNIQUE NOT NULL,
+    previousRefreshTokenHash CHAR(64),
+    revoked BOOLEAN DEFAULT FALSE,
+    expiresAt TIMESTAMP NOT NULL,
+    createdAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (userId) REFERENCES User(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_session_userId ON Session(userId);
+CREATE INDEX idx_session_previousRefreshTokenHash ON Session(previousRefreshTokenHash);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS Session;
@@ -0,0 +1,17 @@
+-- +goose Up
+
+ALTER TABLE Otp ADD COLUMN purpose VARCHAR(30) NOT NULL DEFAULT 'verify_email';
+
+ALTER TABLE Otp DROP INDEX email;
+
+CREATE UNIQUE INDEX idx_otp_email_purpose ON Otp(email, purpose);
+
+-- +goose Down
+
+DROP INDEX idx_otp_email_purpose ON Otp;
+
+DELETE FROM Otp WHERE purpose <> 'verify_email';
+
+CREATE UNIQUE INDEX email ON Otp(email);
+
+ALTER TABLE Otp DROP COLUMN purpose;
@@ -0,0 +1,16 @@
+-- +goose Up
+
+-- Plaintext codes cannot be converted to hashes, users can request a new code
+DELETE FROM Otp;
+
+ALTER TABLE Otp CHANGE otp otpHash CHAR(64) NOT NULL;
+
+ALTER TABLE Otp ADD COLUMN attempts INT NOT NULL DEFAULT 0;
+
+-- +goose Down
+
+DELETE FROM Otp;
+
+ALTER TABLE Otp DROP COLUMN attempts;
+
+ALTER TABLE Otp CHANGE otpHash otp VARCHAR(6) NOT NULL;
@@ -0,0 +1,13 @@
+-- +goose Up
+
+CREATE TABLE RateLimitBucket (
+    bucketKey VARCHAR(255) PRIMARY KEY,
+    tokens DOUBLE NOT NULL,
+    updatedAt TIMESTAMP(6) NOT NULL
+);
+
+CREATE INDEX idx_ratelimitbucket_updatedAt ON RateLimitBucket(updatedAt);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS RateLimitBucket;
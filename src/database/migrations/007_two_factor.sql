@@ -0,0 +1,24 @@
+-- +goose Up
+
+ALTER TABLE User ADD COLUMN totpSecret VARCHAR(255);
+ALTER TABLE User ADD COLUMN totpEnabled BOOLEAN DEFAULT FALSE;
+ALTER TABLE User ADD COLUMN totpLastStep BIGINT;
+
+CREATE TABLE RecoveryCode (
+    id CHAR(36) PRIMARY KEY,
+    userId CHAR(36) NOT NULL,
+    codeHash CHAR(64) NOT NULL,
+    usedAt TIMESTAMP NULL,
+    createdAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (userId) REFERENCES User(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_recoverycode_userId ON RecoveryCode(userId);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS RecoveryCode;
+
+ALTER TABLE User DROP COLUMN totpLastStep;
+ALTER TABLE User DROP COLUMN totpEnabled;
+ALTER TABLE User DROP COLUMN totpSecret;
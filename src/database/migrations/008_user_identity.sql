@@ -0,0 +1,18 @@
+-- +goose Up
+
+CREATE TABLE UserIdentity (
+    id CHAR(36) PRIMARY KEY,
+    userId CHAR(36) NOT NULL,
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(100) NOT NULL,
+    createdAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (userId) REFERENCES User(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_useridentity_provider_subject ON UserIdentity(provider, subject);
+CREATE INDEX idx_useridentity_userId ON UserIdentity(userId);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS UserIdentity;
@@ -0,0 +1,12 @@
+-- +goose Up
+
+-- Existing users keep being able to write blogs
+ALTER TABLE User ADD COLUMN role ENUM('reader', 'author', 'editor', 'admin') NOT NULL DEFAULT 'author';
+
+CREATE INDEX idx_user_role ON User(role);
+
+-- +goose Down
+
+DROP INDEX idx_user_role ON User;
+
+ALTER TABLE User DROP COLUMN role;
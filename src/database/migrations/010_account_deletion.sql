@@ -0,0 +1,11 @@
+-- +goose Up
+
+ALTER TABLE User ADD COLUMN deletionRequestedAt TIMESTAMP NULL;
+
+CREATE INDEX idx_user_deletionRequestedAt ON User(deletionRequestedAt);
+
+-- +goose Down
+
+DROP INDEX idx_user_deletionRequestedAt ON User;
+
+ALTER TABLE User DROP COLUMN deletionRequestedAt;
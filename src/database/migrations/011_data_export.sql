@@ -0,0 +1,18 @@
+-- +goose Up
+
+CREATE TABLE DataExport (
+    id CHAR(36) PRIMARY KEY,
+    userId CHAR(36) NOT NULL,
+    status ENUM('pending', 'ready', 'failed') NOT NULL DEFAULT 'pending',
+    filePath VARCHAR(255),
+    createdAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    completedAt TIMESTAMP NULL,
+    expiresAt TIMESTAMP NULL,
+    FOREIGN KEY (userId) REFERENCES User(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_dataexport_userId ON DataExport(userId);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS DataExport;
@@ -0,0 +1,8 @@
+-- +goose Up
+
+-- New address waiting for its OTP to be verified before it replaces email
+ALTER TABLE User ADD COLUMN pendingEmail VARCHAR(100) NULL;
+
+-- +goose Down
+
+ALTER TABLE User DROP COLUMN pendingEmail;
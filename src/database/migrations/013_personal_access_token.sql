@@ -0,0 +1,21 @@
+-- +goose Up
+
+CREATE TABLE PersonalAccessToken (
+    id CHAR(36) PRIMARY KEY,
+    userId CHAR(36) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    tokenHash CHAR(64) UNIQUE NOT NULL,
+    tokenPrefix VARCHAR(16) NOT NULL,
+    scopes VARCHAR(255) NOT NULL,
+    revoked BOOLEAN DEFAULT FALSE,
+    expiresAt TIMESTAMP NULL,
+    lastUsedAt TIMESTAMP NULL,
+    createdAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (userId) REFERENCES User(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_personalaccesstoken_userId ON PersonalAccessToken(userId);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS PersonalAccessToken;
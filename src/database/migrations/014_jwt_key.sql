@@ -0,0 +1,13 @@
+-- +goose Up
+
+CREATE TABLE JwtKey (
+    id VARCHAR(64) PRIMARY KEY,
+    algorithm VARCHAR(10) NOT NULL,
+    privateKey TEXT NOT NULL,
+    createdAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    retiresAt TIMESTAMP NULL
+);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS JwtKey;
@@ -0,0 +1,17 @@
+-- +goose Up
+
+CREATE TABLE MagicLink (
+    id CHAR(36) PRIMARY KEY,
+    userId CHAR(36) NOT NULL,
+    tokenHash CHAR(64) UNIQUE NOT NULL,
+    expiresAt TIMESTAMP NOT NULL,
+    usedAt TIMESTAMP NULL,
+    createdAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (userId) REFERENCES User(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_magiclink_userId ON MagicLink(userId);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS MagicLink;
@@ -0,0 +1,13 @@
+-- +goose Up
+
+-- Failed logins per account (email:<email>) and per client (ip:<ip>)
+CREATE TABLE LoginFailure (
+    subject VARCHAR(120) PRIMARY KEY,
+    failures INT NOT NULL DEFAULT 0,
+    lastFailureAt TIMESTAMP NOT NULL,
+    lockedUntil TIMESTAMP NULL
+);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS LoginFailure;
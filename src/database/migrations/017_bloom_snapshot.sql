@@ -0,0 +1,11 @@
+-- +goose Up
+
+CREATE TABLE BloomSnapshot (
+    name VARCHAR(50) PRIMARY KEY,
+    data LONGBLOB NOT NULL,
+    updatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS BloomSnapshot;
@@ -0,0 +1,13 @@
+-- +goose Up
+
+ALTER TABLE Session
+    ADD COLUMN userAgent VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN ipAddress VARCHAR(45) NOT NULL DEFAULT '',
+    ADD COLUMN lastSeenAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+
+-- +goose Down
+
+ALTER TABLE Session
+    DROP COLUMN userAgent,
+    DROP COLUMN ipAddress,
+    DROP COLUMN lastSeenAt;
@@ -0,0 +1,37 @@
+-- +goose Up
+
+CREATE TABLE AuditEvent (
+    id CHAR(36) PRIMARY KEY,
+    eventType VARCHAR(50) NOT NULL,
+    userId CHAR(36) NULL, -- No foreign key, events outlive purged accounts
+    email VARCHAR(100) NULL,
+    ipAddress VARCHAR(45) NOT NULL DEFAULT '',
+    userAgent VARCHAR(255) NOT NULL DEFAULT '',
+    requestId VARCHAR(64) NOT NULL DEFAULT '',
+    details JSON NULL,
+    createdAt TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6)
+);
+
+CREATE INDEX idx_auditevent_userId_createdAt ON AuditEvent(userId, createdAt);
+CREATE INDEX idx_auditevent_eventType_createdAt ON AuditEvent(eventType, createdAt);
+CREATE INDEX idx_auditevent_createdAt ON AuditEvent(createdAt);
+
+-- The log is append-only
+-- +goose StatementBegin
+CREATE TRIGGER trg_auditevent_no_update BEFORE UPDATE ON AuditEvent
+FOR EACH ROW
+SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'AuditEvent is append-only';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER trg_auditevent_no_delete BEFORE DELETE ON AuditEvent
+FOR EACH ROW
+SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'AuditEvent is append-only';
+-- +goose StatementEnd
+
+-- +goose Down
+
+DROP TRIGGER IF EXISTS trg_auditevent_no_delete;
+DROP TRIGGER IF EXISTS trg_auditevent_no_update;
+
+DROP TABLE IF EXISTS AuditEvent;
@@ -0,0 +1,13 @@
+-- +goose Up
+
+-- Proof-of-work challenges that were already spent, kept until they expire anyway
+CREATE TABLE UsedPowChallenge (
+    nonce CHAR(32) PRIMARY KEY,
+    expiresAt TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_usedpowchallenge_expiresAt ON UsedPowChallenge(expiresAt);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS UsedPowChallenge;
@@ -0,0 +1,24 @@
+-- +goose Up
+
+CREATE TABLE Invite (
+    id CHAR(36) PRIMARY KEY,
+    codeHash CHAR(64) UNIQUE NOT NULL,
+    email VARCHAR(100) NULL, -- Only this email can use the invite when set
+    maxUses INT NOT NULL DEFAULT 1,
+    uses INT NOT NULL DEFAULT 0,
+    revoked BOOLEAN DEFAULT false,
+    createdBy CHAR(36) NULL,
+    expiresAt TIMESTAMP NOT NULL,
+    createdAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (createdBy) REFERENCES User(id) ON DELETE SET NULL
+);
+
+ALTER TABLE User ADD COLUMN inviteId CHAR(36) NULL;
+ALTER TABLE User ADD CONSTRAINT fk_user_invite FOREIGN KEY (inviteId) REFERENCES Invite(id) ON DELETE SET NULL;
+
+-- +goose Down
+
+ALTER TABLE User DROP FOREIGN KEY fk_user_invite;
+ALTER TABLE User DROP COLUMN inviteId;
+
+DROP TABLE IF EXISTS Invite;
@@ -0,0 +1,17 @@
+-- +goose Up
+
+-- Emails are stored lowercase from now on. Rows that would clash with another account keep their case.
+UPDATE IGNORE User SET email = LOWER(email);
+UPDATE IGNORE User SET pendingEmail = LOWER(pendingEmail) WHERE pendingEmail IS NOT NULL;
+UPDATE IGNORE UserIdentity SET email = LOWER(email);
+UPDATE IGNORE Invite SET email = LOWER(email) WHERE email IS NOT NULL;
+UPDATE IGNORE Otp SET email = LOWER(email);
+
+-- Which mailbox an email reaches, so one mailbox cannot hold several accounts
+ALTER TABLE User ADD COLUMN canonicalEmail VARCHAR(100) NULL;
+CREATE UNIQUE INDEX idx_user_canonicalEmail ON User(canonicalEmail);
+
+-- +goose Down
+
+DROP INDEX idx_user_canonicalEmail ON User;
+ALTER TABLE User DROP COLUMN canonicalEmail;
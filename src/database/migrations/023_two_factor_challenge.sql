@@ -0,0 +1,16 @@
+-- +goose Up
+
+-- Challenge tokens handed out after the password, each one can only be exchanged once
+CREATE TABLE TwoFactorChallenge (
+    id CHAR(36) PRIMARY KEY, -- jti of the challenge token
+    userId CHAR(36) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    expiresAt TIMESTAMP NOT NULL,
+    FOREIGN KEY (userId) REFERENCES User(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_twofactorchallenge_expiresAt ON TwoFactorChallenge(expiresAt);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS TwoFactorChallenge;
@@ -0,0 +1,8 @@
+-- +goose Up
+
+-- Users who existed when roles were added were made authors, new signups start as readers
+ALTER TABLE User ALTER COLUMN role SET DEFAULT 'reader';
+
+-- +goose Down
+
+ALTER TABLE User ALTER COLUMN role SET DEFAULT 'author';
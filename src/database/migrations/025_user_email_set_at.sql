@@ -0,0 +1,14 @@
+-- +goose Up
+
+-- When the current email was set, the Bloom filter sync reads User rows in (emailSetAt, id) order
+ALTER TABLE User ADD COLUMN emailSetAt TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6);
+
+UPDATE User SET emailSetAt = createdAt WHERE createdAt IS NOT NULL;
+
+CREATE INDEX idx_user_emailSetAt_id ON User(emailSetAt, id);
+
+-- +goose Down
+
+DROP INDEX idx_user_emailSetAt_id ON User;
+
+ALTER TABLE User DROP COLUMN emailSetAt;
@@ -0,0 +1,12 @@
+-- +goose Up
+
+-- Proof-of-work challenges issued and redeemed per minute, the difficulty grows with them
+CREATE TABLE PowChallengeActivity (
+    bucket TIMESTAMP PRIMARY KEY,
+    issued INT NOT NULL DEFAULT 0,
+    redeemed INT NOT NULL DEFAULT 0
+);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS PowChallengeActivity;
@@ -0,0 +1,11 @@
+-- +goose Up
+
+-- 022 left canonicalEmail NULL. Every row gets a unique placeholder
+-- here, the app recomputes them with the provider rules on its next start and logs any collisions.
+UPDATE User SET canonicalEmail = CONCAT('unresolved:', id);
+ALTER TABLE User MODIFY canonicalEmail VARCHAR(100) NOT NULL;
+
+-- +goose Down
+
+ALTER TABLE User MODIFY canonicalEmail VARCHAR(100) NULL;
+UPDATE User SET canonicalEmail = NULL WHERE canonicalEmail LIKE 'unresolved:%' OR canonicalEmail LIKE 'collision:%';
@@ -0,0 +1,10 @@
+-- +goose Up
+
+-- Wrong attempts are counted from here rather than from the last resend, so resending cannot reset them
+ALTER TABLE Otp ADD COLUMN attemptsSince TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+
+UPDATE Otp SET attemptsSince = createdAt;
+
+-- +goose Down
+
+ALTER TABLE Otp DROP COLUMN attemptsSince;
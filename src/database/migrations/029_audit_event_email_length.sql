@@ -0,0 +1,8 @@
+-- +goose Up
+
+-- Failed logins record whatever address was typed, which the validator allows up to 254 characters
+ALTER TABLE AuditEvent MODIFY email VARCHAR(255) NULL;
+
+-- +goose Down
+
+ALTER TABLE AuditEvent MODIFY email VARCHAR(100) NULL;
@@ -0,0 +1,8 @@
+-- +goose Up
+
+-- Rules each canonicalEmail was computed with. Empty matches no version, so every row is recomputed once.
+ALTER TABLE User ADD COLUMN canonicalEmailVersion VARCHAR(20) NOT NULL DEFAULT '';
+
+-- +goose Down
+
+ALTER TABLE User DROP COLUMN canonicalEmailVersion;
@@ -0,0 +1,16 @@
+-- +goose Up
+
+-- Emails that left the User table, so every replica can take them out of its Bloom filter on the next sync
+CREATE TABLE EmailBloomRemoval (
+    id CHAR(36) PRIMARY KEY,
+    userId CHAR(36) NOT NULL, -- No foreign key, the user is usually deleted with it
+    email VARCHAR(100) NOT NULL,
+    emailSetAt TIMESTAMP(6) NOT NULL, -- Where the email was in the sync order, to tell whether a filter counted it
+    createdAt TIMESTAMP(6) DEFAULT CURRENT_TIMESTAMP(6)
+);
+
+CREATE INDEX idx_emailbloomremoval_createdAt_id ON EmailBloomRemoval(createdAt, id);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS EmailBloomRemoval;
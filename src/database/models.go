@@ -1,14 +1,31 @@
 package database
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type User struct {
 	ID        string    `json:"id" db:"id"` // UUID
 	Email     string    `json:"email" db:"email"`
-	Password  string    `json:"password" db:"password"`
+	Password  string    `json:"-" db:"password"` // Never serialized
 	Verified  bool      `json:"verified" db:"verified"`
+	Role      string    `json:"role" db:"role"` // reader, author, editor or admin
 	CreatedAt time.Time `json:"createdAt" db:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt" db:"updatedAt"`
+
+	TotpSecret   string `json:"-" db:"totpSecret"` // Encrypted, empty until 2FA enrolment starts
+	TotpEnabled  bool   `json:"totpEnabled" db:"totpEnabled"`
+	TotpLastStep int64  `json:"-" db:"totpLastStep"` // Last accepted time step, stops codes being replayed
+
+	PendingEmail *string `json:"pendingEmail" db:"pendingEmail"` // Waiting for its OTP before it replaces Email
+
+	InviteID *string `json:"inviteId" db:"inviteId"` // Invite used to sign up, if any
+
+	CanonicalEmail        string `json:"-" db:"canonicalEmail"`        // Email after provider rules, unique per mailbox, or a collision: placeholder
+	CanonicalEmailVersion string `json:"-" db:"canonicalEmailVersion"` // Rules CanonicalEmail was computed with
+
+	EmailSetAt time.Time `json:"-" db:"emailSetAt"` // When Email was set, the Bloom filter sync follows it
 }
 
 type Profile struct {
@@ -35,6 +52,144 @@ type Blog struct {
 type Otp struct {
 	ID        string    `json:"id" db:"id"` // UUID
 	Email     string    `json:"email" db:"email"`
-	Otp       string    `json:"otp" db:"otp"`
+	OtpHash   string    `json:"-" db:"otpHash"`       // HMAC of the code, never the code itself
+	Purpose   string    `json:"purpose" db:"purpose"` // What the code may be used for
+	Attempts  int       `json:"attempts" db:"attempts"`
+	CreatedAt time.Time `json:"createdAt" db:"createdAt"`
+
+	AttemptsSince time.Time `json:"attemptsSince" db:"attemptsSince"` // Start of the window wrong attempts are counted in, resends keep it
+}
+
+type Session struct {
+	ID                       string    `json:"id" db:"id"`         // UUID
+	UserID                   string    `json:"userId" db:"userId"` // Foreign key to User
+	RefreshTokenHash         string    `json:"-" db:"refreshTokenHash"`
+	PreviousRefreshTokenHash string    `json:"-" db:"previousRefreshTokenHash"`
+	Revoked                  bool      `json:"revoked" db:"revoked"`
+	UserAgent                string    `json:"userAgent" db:"userAgent"` // Device the login came from
+	IPAddress                string    `json:"ipAddress" db:"ipAddress"`
+	ExpiresAt                time.Time `json:"expiresAt" db:"expiresAt"`
+	LastSeenAt               time.Time `json:"lastSeenAt" db:"lastSeenAt"` // Updated at most once a minute
+	CreatedAt                time.Time `json:"createdAt" db:"createdAt"`
+	UpdatedAt                time.Time `json:"updatedAt" db:"updatedAt"`
+}
+
+type RecoveryCode struct {
+	ID        string     `json:"id" db:"id"`         // UUID
+	UserID    string     `json:"userId" db:"userId"` // Foreign key to User
+	CodeHash  string     `json:"-" db:"codeHash"`
+	UsedAt    *time.Time `json:"usedAt" db:"usedAt"`
+	CreatedAt time.Time  `json:"createdAt" db:"createdAt"`
+}
+
+type UserIdentity struct {
+	ID        string    `json:"id" db:"id"`             // UUID
+	UserID    string    `json:"userId" db:"userId"`     // Foreign key to User
+	Provider  string    `json:"provider" db:"provider"` // google, github or the configured OIDC provider
+	Subject   string    `json:"subject" db:"subject"`   // User id at the provider
+	Email     string    `json:"email" db:"email"`
+	CreatedAt time.Time `json:"createdAt" db:"createdAt"`
+}
+
+type DataExport struct {
+	ID          string     `json:"id" db:"id"`         // UUID
+	UserID      string     `json:"userId" db:"userId"` // Foreign key to User
+	Status      string     `json:"status" db:"status"` // pending, ready or failed
+	FilePath    string     `json:"-" db:"filePath"`
+	CreatedAt   time.Time  `json:"createdAt" db:"createdAt"`
+	CompletedAt *time.Time `json:"completedAt" db:"completedAt"`
+	ExpiresAt   *time.Time `json:"expiresAt" db:"expiresAt"`
+}
+
+type PersonalAccessToken struct {
+	ID          string     `json:"id" db:"id"`         // UUID
+	UserID      string     `json:"userId" db:"userId"` // Foreign key to User
+	Name        string     `json:"name" db:"name"`
+	TokenHash   string     `json:"-" db:"tokenHash"`
+	TokenPrefix string     `json:"tokenPrefix" db:"tokenPrefix"` // Start of the token, to tell tokens apart
+	Scopes      []string   `json:"scopes" db:"scopes"`           // Stored comma separated
+	Revoked     bool       `json:"revoked" db:"revoked"`
+	ExpiresAt   *time.Time `json:"expiresAt" db:"expiresAt"` // Never expires when nil
+	LastUsedAt  *time.Time `json:"lastUsedAt" db:"lastUsedAt"`
+	CreatedAt   time.Time  `json:"createdAt" db:"createdAt"`
+}
+
+type JwtKey struct {
+	ID         string     `json:"id" db:"id"`               // kid header of the tokens it signs
+	Algorithm  string     `json:"algorithm" db:"algorithm"` // RS256 or EdDSA
+	PrivateKey string     `json:"-" db:"privateKey"`        // Encrypted PKCS8 PEM
+	CreatedAt  time.Time  `json:"createdAt" db:"createdAt"`
+	RetiresAt  *time.Time `json:"retiresAt" db:"retiresAt"` // Set once a newer key replaces it
+}
+
+type MagicLink struct {
+	ID        string     `json:"id" db:"id"`         // UUID
+	UserID    string     `json:"userId" db:"userId"` // Foreign key to User
+	TokenHash string     `json:"-" db:"tokenHash"`
+	ExpiresAt time.Time  `json:"expiresAt" db:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt" db:"usedAt"` // Links can only be used once
+	CreatedAt time.Time  `json:"createdAt" db:"createdAt"`
+}
+
+type LoginFailure struct {
+	Subject       string     `json:"subject" db:"subject"` // email:<email> or ip:<ip>
+	Failures      int        `json:"failures" db:"failures"`
+	LastFailureAt time.Time  `json:"lastFailureAt" db:"lastFailureAt"`
+	LockedUntil   *time.Time `json:"lockedUntil" db:"lockedUntil"`
+}
+
+type Invite struct {
+	ID        string    `json:"id" db:"id"` // UUID
+	CodeHash  string    `json:"-" db:"codeHash"`
+	Email     *string   `json:"email" db:"email"` // Only this email can use the invite when set
+	MaxUses   int       `json:"maxUses" db:"maxUses"`
+	Uses      int       `json:"uses" db:"uses"`
+	Revoked   bool      `json:"revoked" db:"revoked"`
+	CreatedBy *string   `json:"createdBy" db:"createdBy"` // Admin who created it
+	ExpiresAt time.Time `json:"expiresAt" db:"expiresAt"`
 	CreatedAt time.Time `json:"createdAt" db:"createdAt"`
 }
+
+type UsedPowChallenge struct {
+	Nonce     string    `json:"nonce" db:"nonce"`
+	ExpiresAt time.Time `json:"expiresAt" db:"expiresAt"`
+}
+
+type PowChallengeActivity struct {
+	Bucket   time.Time `json:"bucket" db:"bucket"` // Start of the minute
+	Issued   int       `json:"issued" db:"issued"`
+	Redeemed int       `json:"redeemed" db:"redeemed"`
+}
+
+type TwoFactorChallenge struct {
+	ID        string    `json:"id" db:"id"`         // jti of the challenge token
+	UserID    string    `json:"userId" db:"userId"` // Foreign key to User
+	Attempts  int       `json:"attempts" db:"attempts"`
+	ExpiresAt time.Time `json:"expiresAt" db:"expiresAt"`
+}
+
+type BloomSnapshot struct {
+	Name      string    `json:"name" db:"name"` // Which filter, e.g. email
+	Data      []byte    `json:"-" db:"data"`    // Sizing, sync time and the filter from WriteTo
+	UpdatedAt time.Time `json:"updatedAt" db:"updatedAt"`
+}
+
+type EmailBloomRemoval struct {
+	ID         string    `json:"id" db:"id"`                 // UUID
+	UserID     string    `json:"userId" db:"userId"`         // Not a foreign key, the user is usually deleted
+	Email      string    `json:"email" db:"email"`           // Email to take out of the Bloom filter
+	EmailSetAt time.Time `json:"emailSetAt" db:"emailSetAt"` // Where the email was in the sync order
+	CreatedAt  time.Time `json:"createdAt" db:"createdAt"`
+}
+
+type AuditEvent struct {
+	ID        string          `json:"id" db:"id"`               // UUID
+	EventType string          `json:"eventType" db:"eventType"` // e.g. login_failed, password_changed
+	UserID    *string         `json:"userId" db:"userId"`       // Not a foreign key, events outlive accounts
+	Email     *string         `json:"email" db:"email"`
+	IPAddress string          `json:"ipAddress" db:"ipAddress"`
+	UserAgent string          `json:"userAgent" db:"userAgent"`
+	RequestID string          `json:"requestId" db:"requestId"`
+	Details   json.RawMessage `json:"details" db:"details"`
+	CreatedAt time.Time       `json:"createdAt" db:"createdAt"`
+}
@@ -1,7 +1,9 @@
 package dto
 
 type ResendOtpRequest struct {
-	Email string `json:"email" validate:"required,email,excludesall=;()="`
+	Email     string `json:"email" validate:"required,email,excludesall=;()="`
+	Challenge string `json:"challenge" validate:"required,max=200,excludesall=;()="`
+	Solution  string `json:"solution" validate:"required,max=64,excludesall=;()="`
 }
 
 type VerifyUserRequest struct {
@@ -10,15 +12,26 @@ type VerifyUserRequest struct {
 }
 
 type SignupRequest struct {
-	Email     string `json:"email" validate:"required,email,excludesall=;()="`
-	Password  string `json:"password" validate:"required,excludesall=;()="`
-	FirstName string `json:"firstName" validate:"required,excludesall=;()="`
-	LastName  string `json:"lastName" validate:"required,excludesall=;()="`
+	Email      string `json:"email" validate:"required,email,excludesall=;()="`
+	Password   string `json:"password" validate:"required,max=256"`
+	FirstName  string `json:"firstName" validate:"required,excludesall=;()="`
+	LastName   string `json:"lastName" validate:"required,excludesall=;()="`
+	Challenge  string `json:"challenge" validate:"required,max=200,excludesall=;()="`
+	Solution   string `json:"solution" validate:"required,max=64,excludesall=;()="`
+	InviteCode string `json:"inviteCode" validate:"omitempty,max=100,excludesall=;()="` // Needed when registration is invite only
 }
 
 type LoginRequest struct {
 	Email    string `json:"email" validate:"required,email,excludesall=;()="`
-	Password string `json:"password" validate:"required,excludesall=;()="`
+	Password string `json:"password" validate:"required,max=256"`
+}
+
+type MagicLinkRequest struct {
+	Email string `json:"email" validate:"required,email,excludesall=;()="`
+}
+
+type MagicLinkLoginRequest struct {
+	Token string `json:"token" validate:"required,max=100"`
 }
 
 type UpdateProfileRequest struct {
@@ -26,3 +39,72 @@ type UpdateProfileRequest struct {
 	LastName  string `json:"lastName" form:"lastName" validate:"omitempty,min=2,max=50"`
 	// Note: Image is handled separately in the form-data, so it's not included here.
 }
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email,excludesall=;()="`
+}
+
+type ResetPasswordRequest struct {
+	Email       string `json:"email" validate:"required,email,excludesall=;()="`
+	Otp         string `json:"otp" validate:"required,len=6,excludesall=;()="`
+	NewPassword string `json:"newPassword" validate:"required,max=256"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" validate:"required,max=256"`
+	NewPassword     string `json:"newPassword" validate:"required,max=256"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password     string `json:"password" validate:"required,max=256"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	RecoveryCode string `json:"recoveryCode" validate:"required_without=Code,omitempty,max=20,excludesall=;()="`
+}
+
+type VerifyTwoFactorRequest struct {
+	ChallengeToken string `json:"challengeToken" validate:"required"`
+	Code           string `json:"code" validate:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	RecoveryCode   string `json:"recoveryCode" validate:"required_without=Code,omitempty,max=20,excludesall=;()="`
+}
+
+type ChangeEmailRequest struct {
+	NewEmail string `json:"newEmail" validate:"required,email,max=100,excludesall=;()="`
+	Password string `json:"password" validate:"required,max=256"`
+}
+
+type ConfirmEmailChangeRequest struct {
+	Otp string `json:"otp" validate:"required,len=6,excludesall=;()="`
+}
+
+type CreatePersonalAccessTokenRequest struct {
+	Name          string   `json:"name" validate:"required,max=100,excludesall=;()="`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,oneof=blog:read blog:write"`
+	ExpiresInDays int      `json:"expiresInDays" validate:"omitempty,min=1,max=365"` // Never expires when left out
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password" validate:"required,max=256"`
+}
+
+type RestoreAccountRequest struct {
+	Email    string `json:"email" validate:"required,email,excludesall=;()="`
+	Password string `json:"password" validate:"required,max=256"`
+}
+
+type CreateInviteRequest struct {
+	MaxUses        int    `json:"maxUses" validate:"omitempty,min=1,max=1000"`        // Single use when left out
+	ExpiresInHours int    `json:"expiresInHours" validate:"omitempty,min=1,max=8760"` // A week when left out
+	Email          string `json:"email" validate:"omitempty,email,excludesall=;()="`  // Locks the invite to this email and sends it there
+}
+
+type UpdateRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=reader author editor admin"`
+}
@@ -0,0 +1,106 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/config"
+	"github.com/Sahil2k07/Blog-App-Go/src/utils"
+)
+
+// The account was restored between listing and purging it
+var errAccountRestored = errors.New("account deletion was cancelled")
+
+// Hard delete accounts whose deletion grace period is over, every interval
+func StartAccountPurger(interval time.Duration) {
+	go func() {
+		for {
+			purgeDeletedAccounts()
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func purgeDeletedAccounts() {
+	rows, err := config.DB.Query(
+		`
+			SELECT u.id, u.email, p.image
+			FROM User u
+			LEFT JOIN Profile p ON u.id = p.userId
+			WHERE u.deletionRequestedAt IS NOT NULL AND u.deletionRequestedAt < NOW() - INTERVAL ? DAY
+		`, int(utils.AccountDeletionGracePeriod().Hours()/24),
+	)
+	if err != nil {
+		log.Printf("Failed to find accounts to purge: %v", err)
+		return
+	}
+
+	type account struct {
+		id    string
+		email string
+		image *string
+	}
+
+	var accounts []account
+
+	for rows.Next() {
+		var a account
+		if err := rows.Scan(&a.id, &a.email, &a.image); err != nil {
+			log.Printf("Failed to read account to purge: %v", err)
+			continue
+		}
+		accounts = append(accounts, a)
+	}
+	rows.Close()
+
+	for _, a := range accounts {
+		if a.image != nil {
+			if err := config.DeleteCloudinaryImage(*a.image); err != nil {
+				// Keep the account, so the image is retried on the next run
+				log.Printf("Failed to delete avatar of account %s: %v", a.id, err)
+				continue
+			}
+		}
+
+		removeDataExportFiles(a.id)
+
+		err := config.RunInTransaction(context.Background(), func(uow *config.UnitOfWork) error {
+			if err := config.RemoveEmailFromBloom(uow.Context(), uow, a.id); err != nil {
+				return err
+			}
+
+			// Profile, Blogs, Sessions and the rest cascade from the User row
+			result, err := uow.Exec(
+				`
+					DELETE FROM User
+					WHERE id = ? AND deletionRequestedAt IS NOT NULL
+				`, a.id,
+			)
+			if err != nil {
+				return err
+			}
+
+			// Restored since it was listed, the removal rolls back with the delete
+			if rowsAffected, err := result.RowsAffected(); err != nil {
+				return err
+			} else if rowsAffected != 1 {
+				return errAccountRestored
+			}
+
+			return nil
+		})
+		if errors.Is(err, errAccountRestored) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed to purge account %s: %v", a.id, err)
+			continue
+		}
+
+		config.DB.Exec(`DELETE FROM Otp WHERE email = ?`, a.email)
+
+		log.Printf("Purged account %s", a.id)
+	}
+}
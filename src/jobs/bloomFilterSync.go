@@ -0,0 +1,42 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/config"
+)
+
+// How often the email Bloom filter is rebuilt from scratch
+const bloomFilterRebuildInterval = 24 * time.Hour
+
+// Catch the email Bloom filter up with the User table and snapshot it, every interval.
+// Keeps replicas close to each other and makes the next boot cheap.
+func StartBloomFilterSync(interval time.Duration) {
+	go func() {
+		lastRebuild := time.Now()
+
+		for {
+			time.Sleep(interval)
+
+			syncFilter := config.SyncBloomFilter
+			if time.Since(lastRebuild) >= bloomFilterRebuildInterval {
+				syncFilter = config.RebuildBloomFilter
+				lastRebuild = time.Now()
+			}
+
+			if err := syncFilter(); err != nil {
+				log.Printf("Failed to sync the Bloom filter: %v", err)
+				continue
+			}
+
+			if err := config.SnapshotBloomFilter(); err != nil {
+				log.Printf("Failed to snapshot the Bloom filter: %v", err)
+			}
+
+			metrics := config.EmailBloomMetrics()
+			log.Printf("Bloom filter: %v checks, %v MySQL fallbacks, %v false positives",
+				metrics["checks"], metrics["mysqlFallbacks"], metrics["falsePositives"])
+		}
+	}()
+}
@@ -0,0 +1,319 @@
+package jobs
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/config"
+	"github.com/Sahil2k07/Blog-App-Go/src/database"
+)
+
+// How long a finished export can be downloaded
+const DataExportTTL = 7 * 24 * time.Hour
+
+// Pending exports older than this were lost, e.g. to a restart mid-build, and are marked failed
+const DataExportStaleAfter = 30 * time.Minute
+
+// Directory where background exports are written. Downloads read the file from the same
+// path, so with several replicas it has to be storage they all share, such as a mounted volume.
+func DataExportDir() string {
+	dir := os.Getenv("EXPORT_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "blog-app-exports")
+	}
+
+	return dir
+}
+
+func parseDBTime(value []byte) (time.Time, error) {
+	return time.Parse("2006-01-02 15:04:05", string(value))
+}
+
+// Write a zip archive with everything stored about the user
+func WriteDataExport(userId string, w io.Writer) error {
+	var user database.User
+	var createdAtBytes, updatedAtBytes []byte
+
+	err := config.DB.QueryRow(
+		`
+			SELECT id, email, pendingEmail, verified, role, totpEnabled, createdAt, updatedAt
+			FROM User
+			WHERE id = ?
+		`, userId,
+	).Scan(&user.ID, &user.Email, &user.PendingEmail, &user.Verified, &user.Role, &user.TotpEnabled, &createdAtBytes, &updatedAtBytes)
+	if err != nil {
+		return fmt.Errorf("failed to load user: %w", err)
+	}
+
+	if user.CreatedAt, err = parseDBTime(createdAtBytes); err != nil {
+		return err
+	}
+	if user.UpdatedAt, err = parseDBTime(updatedAtBytes); err != nil {
+		return err
+	}
+
+	var profile database.Profile
+
+	err = config.DB.QueryRow(
+		`
+			SELECT id, userId, firstName, lastName, image, createdAt, updatedAt
+			FROM Profile
+			WHERE userId = ?
+		`, userId,
+	).Scan(&profile.ID, &profile.UserID, &profile.FirstName, &profile.LastName, &profile.Image, &createdAtBytes, &updatedAtBytes)
+	if err != nil {
+		return fmt.Errorf("failed to load profile: %w", err)
+	}
+
+	if profile.CreatedAt, err = parseDBTime(createdAtBytes); err != nil {
+		return err
+	}
+	if profile.UpdatedAt, err = parseDBTime(updatedAtBytes); err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(w)
+
+	writeJSON := func(name string, value interface{}) error {
+		file, err := archive.Create(name)
+		if err != nil {
+			return err
+		}
+
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(value)
+	}
+
+	if err := writeJSON("user.json", user); err != nil {
+		return err
+	}
+
+	if err := writeJSON("profile.json", profile); err != nil {
+		return err
+	}
+
+	// Blogs are streamed one at a time, so large accounts do not need to fit in memory
+	rows, err := config.DB.Query(
+		`
+			SELECT id, profileId, title, content, tags, published, createdAt, updatedAt
+			FROM Blog
+			WHERE profileId = ?
+			ORDER BY createdAt
+		`, profile.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to load blogs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var blog database.Blog
+		var tagsJSON []byte
+
+		err := rows.Scan(&blog.ID, &blog.ProfileID, &blog.Title, &blog.Content, &tagsJSON, &blog.Published, &createdAtBytes, &updatedAtBytes)
+		if err != nil {
+			return err
+		}
+
+		if len(tagsJSON) > 0 {
+			if err := json.Unmarshal(tagsJSON, &blog.Tags); err != nil {
+				return err
+			}
+		}
+
+		if blog.CreatedAt, err = parseDBTime(createdAtBytes); err != nil {
+			return err
+		}
+		if blog.UpdatedAt, err = parseDBTime(updatedAtBytes); err != nil {
+			return err
+		}
+
+		if err := writeJSON("blogs/"+blog.ID+".json", blog); err != nil {
+			return err
+		}
+
+		file, err := archive.Create("blogs/" + blog.ID + ".md")
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.WriteString(file, blogMarkdown(blog)); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
+
+// Blog as Markdown with its metadata in front matter
+func blogMarkdown(blog database.Blog) string {
+	tagsJSON, _ := json.Marshal(blog.Tags)
+	titleJSON, _ := json.Marshal(blog.Title)
+
+	var b strings.Builder
+
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "title: %s\n", titleJSON)
+	fmt.Fprintf(&b, "tags: %s\n", tagsJSON)
+	fmt.Fprintf(&b, "published: %t\n", blog.Published)
+	fmt.Fprintf(&b, "createdAt: %s\n", blog.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "updatedAt: %s\n", blog.UpdatedAt.Format(time.RFC3339))
+	b.WriteString("---\n\n")
+	b.WriteString(blog.Content)
+	b.WriteString("\n")
+
+	return b.String()
+}
+
+// Build the export of a user in the background and record the result on the DataExport row
+func StartDataExport(exportId, userId string) {
+	go func() {
+		filePath := filepath.Join(DataExportDir(), exportId+".zip")
+
+		err := func() error {
+			if err := os.MkdirAll(DataExportDir(), 0o700); err != nil {
+				return err
+			}
+
+			file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			if err := WriteDataExport(userId, file); err != nil {
+				return err
+			}
+
+			return file.Close()
+		}()
+		if err != nil {
+			log.Printf("Failed to build data export %s: %v", exportId, err)
+			os.Remove(filePath)
+
+			config.DB.Exec(
+				`
+					UPDATE DataExport
+					SET status = 'failed', completedAt = NOW()
+					WHERE id = ?
+				`, exportId,
+			)
+			return
+		}
+
+		result, err := config.DB.Exec(
+			`
+				UPDATE DataExport
+				SET status = 'ready', filePath = ?, completedAt = NOW(), expiresAt = NOW() + INTERVAL ? SECOND
+				WHERE id = ? AND status = 'pending'
+			`, filePath, int(DataExportTTL.Seconds()), exportId,
+		)
+		if err != nil {
+			log.Printf("Failed to record data export %s: %v", exportId, err)
+		} else if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+			// Taken for stale and marked failed meanwhile, a newer export replaces it
+			os.Remove(filePath)
+		}
+	}()
+}
+
+// Remove expired export archives, every interval
+func StartDataExportCleaner(interval time.Duration) {
+	go func() {
+		for {
+			cleanExpiredDataExports()
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// Mark exports whose build was lost as failed, so a new one can be started
+func FailStaleDataExports(userId string) error {
+	_, err := config.DB.Exec(
+		`
+			UPDATE DataExport
+			SET status = 'failed', completedAt = NOW()
+			WHERE status = 'pending' AND createdAt < NOW() - INTERVAL ? SECOND AND (? = '' OR userId = ?)
+		`, int(DataExportStaleAfter.Seconds()), userId, userId,
+	)
+	return err
+}
+
+func cleanExpiredDataExports() {
+	if err := FailStaleDataExports(""); err != nil {
+		log.Printf("Failed to mark stale data exports: %v", err)
+	}
+
+	rows, err := config.DB.Query(
+		`
+			SELECT id, filePath FROM DataExport
+			WHERE expiresAt < NOW() OR (status = 'failed' AND completedAt < NOW() - INTERVAL 1 DAY)
+		`,
+	)
+	if err != nil {
+		log.Printf("Failed to find expired data exports: %v", err)
+		return
+	}
+
+	var ids, paths []string
+
+	for rows.Next() {
+		var id string
+		var filePath *string
+
+		if err := rows.Scan(&id, &filePath); err != nil {
+			continue
+		}
+
+		ids = append(ids, id)
+		if filePath != nil {
+			paths = append(paths, *filePath)
+		}
+	}
+	rows.Close()
+
+	for _, filePath := range paths {
+		if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to remove data export %s: %v", filePath, err)
+		}
+	}
+
+	for _, id := range ids {
+		config.DB.Exec(`DELETE FROM DataExport WHERE id = ?`, id)
+	}
+}
+
+// Remove the export archives of a user, their rows go away with the User
+func removeDataExportFiles(userId string) {
+	rows, err := config.DB.Query(
+		`
+			SELECT filePath FROM DataExport
+			WHERE userId = ? AND filePath IS NOT NULL
+		`, userId,
+	)
+	if err != nil {
+		log.Printf("Failed to find data exports of %s: %v", userId, err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var filePath string
+		if err := rows.Scan(&filePath); err == nil {
+			os.Remove(filePath)
+		}
+	}
+}
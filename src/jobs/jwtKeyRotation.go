@@ -0,0 +1,30 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/config"
+	"github.com/Sahil2k07/Blog-App-Go/src/utils"
+)
+
+// Rotate the JWT signing keys and reload them from the database, every interval
+func StartJWTKeyRotator(interval time.Duration) {
+	if config.JWTAlgorithm() == utils.JWTAlgorithmHS256 {
+		return
+	}
+
+	go func() {
+		for {
+			time.Sleep(interval)
+
+			if err := config.RotateJWTKeys(); err != nil {
+				log.Printf("Failed to rotate JWT keys: %v", err)
+			}
+
+			if err := config.LoadJWTKeys(); err != nil {
+				log.Printf("Failed to reload JWT keys: %v", err)
+			}
+		}
+	}()
+}
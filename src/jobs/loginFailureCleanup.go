@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/config"
+	"github.com/Sahil2k07/Blog-App-Go/src/utils"
+)
+
+// Delete failed login counters that no longer delay or lock anyone, and expired
+// two-factor challenges, every interval
+func StartLoginFailureCleaner(interval time.Duration) {
+	go func() {
+		for {
+			_, err := config.DB.Exec(
+				`
+					DELETE FROM LoginFailure
+					WHERE lastFailureAt < NOW() - INTERVAL ? SECOND
+						AND (lockedUntil IS NULL OR lockedUntil < NOW())
+				`, int(utils.LoginFailureWindow.Seconds()),
+			)
+			if err != nil {
+				log.Printf("Failed to clean up login failures: %v", err)
+			}
+
+			_, err = config.DB.Exec(`DELETE FROM TwoFactorChallenge WHERE expiresAt < NOW()`)
+			if err != nil {
+				log.Printf("Failed to clean up two-factor challenges: %v", err)
+			}
+
+			time.Sleep(interval)
+		}
+	}()
+}
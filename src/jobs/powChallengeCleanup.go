@@ -0,0 +1,39 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/config"
+	"github.com/Sahil2k07/Blog-App-Go/src/utils"
+)
+
+// Forget used proof-of-work challenges that have expired anyway and activity older than
+// the difficulty window, every interval
+func StartPowChallengeCleaner(interval time.Duration) {
+	go func() {
+		for {
+			_, err := config.DB.Exec(
+				`
+					DELETE FROM UsedPowChallenge
+					WHERE expiresAt < NOW()
+				`,
+			)
+			if err != nil {
+				log.Printf("Failed to clean up used challenges: %v", err)
+			}
+
+			_, err = config.DB.Exec(
+				`
+					DELETE FROM PowChallengeActivity
+					WHERE bucket < NOW() - INTERVAL ? SECOND
+				`, int(utils.PowActivityWindow.Seconds()),
+			)
+			if err != nil {
+				log.Printf("Failed to clean up challenge activity: %v", err)
+			}
+
+			time.Sleep(interval)
+		}
+	}()
+}
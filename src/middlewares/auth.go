@@ -2,9 +2,12 @@ package middlewares
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"net/http"
 	"strings"
 
+	"github.com/Sahil2k07/Blog-App-Go/src/config"
 	"github.com/Sahil2k07/Blog-App-Go/src/utils"
 )
 
@@ -12,12 +15,20 @@ type UserAuthDetails struct {
 	Id        string
 	Email     string
 	ProfileId string
+	SessionId string
+	Role      string
 	Verified  bool
+
+	// Set when a personal access token was used instead of a Session
+	TokenId string
+	Scopes  []string
 }
 
 var UserContext = &struct{}{}
 
-func Auth(next http.Handler) http.Handler {
+// Only let authenticated users through. Personal access tokens are accepted when the
+// route lists the scopes it needs, routes without scopes need a Session login.
+func Auth(next http.Handler, scopes ...string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		cookie, err := r.Cookie("auth_token")
 		var tokenString string
@@ -36,23 +47,84 @@ func Auth(next http.Handler) http.Handler {
 			return
 		}
 
+		if strings.HasPrefix(tokenString, PersonalAccessTokenPrefix) {
+			if len(scopes) == 0 {
+				utils.Forbidden(w, "Personal access tokens cannot be used here. Please login")
+				return
+			}
+
+			user, err := personalAccessTokenUser(tokenString)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					utils.UnAuthorized(w, "Token is invalid, expired or revoked")
+					return
+				}
+				utils.InternalServerError(w, "Problem in Identifying Token")
+				return
+			}
+
+			if !user.HasScopes(scopes...) {
+				utils.Forbidden(w, "Token is missing the scope: "+strings.Join(scopes, ", "))
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), UserContext, user)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
 		// Validate the token using the ValidateJWT function
-		id, email, profileId, verified, err := utils.ValidateJWT(tokenString)
+		claims, err := utils.ValidateJWT(tokenString)
 		if err != nil {
-			utils.InternalServerError(w, err.Error())
+			utils.UnAuthorized(w, err.Error())
 			return
 		}
 
-		if !verified {
+		if !claims.Verified {
 			utils.UnAuthorized(w, "User's email is not verified")
 			return
 		}
 
+		// Tokens are only as good as the Session they were issued for
+		var revoked bool
+
+		err = config.DB.QueryRow(
+			`
+				SELECT revoked FROM Session
+				WHERE id = ? AND userId = ?
+			`, claims.SessionId, claims.Id,
+		).Scan(&revoked)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.UnAuthorized(w, "Session not found. Please login again")
+				return
+			}
+			utils.InternalServerError(w, "Problem in Identifying Session")
+			return
+		}
+
+		if revoked {
+			utils.UnAuthorized(w, "Session has been revoked. Please login again")
+			return
+		}
+
+		// Cheap enough to do inline, as it writes at most once a minute per session
+		config.DB.Exec(
+			`
+				UPDATE Session
+				SET lastSeenAt = NOW()
+				WHERE id = ? AND lastSeenAt < NOW() - INTERVAL 1 MINUTE
+			`, claims.SessionId,
+		)
+
 		user := &UserAuthDetails{
-			Id:        id,
-			Email:     email,
-			ProfileId: profileId,
-			Verified:  verified,
+			Id:        claims.Id,
+			Email:     claims.Email,
+			ProfileId: claims.ProfileId,
+			SessionId: claims.SessionId,
+			Role:      claims.Role,
+			Verified:  claims.Verified,
 		}
 
 		ctx := context.WithValue(r.Context(), UserContext, user)
@@ -60,3 +132,30 @@ func Auth(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// Load the owner of an active personal access token
+func personalAccessTokenUser(token string) (*UserAuthDetails, error) {
+	var user UserAuthDetails
+	var scopes string
+
+	err := config.DB.QueryRow(
+		`
+			SELECT t.id, t.scopes, u.id, u.email, u.role, u.verified, p.id
+			FROM PersonalAccessToken t
+			JOIN User u ON u.id = t.userId
+			JOIN Profile p ON p.userId = u.id
+			WHERE t.tokenHash = ? AND t.revoked = false
+				AND (t.expiresAt IS NULL OR t.expiresAt > NOW())
+				AND u.verified = true AND u.deletionRequestedAt IS NULL
+		`, utils.HashToken(token),
+	).Scan(&user.TokenId, &scopes, &user.Id, &user.Email, &user.Role, &user.Verified, &user.ProfileId)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Scopes = ParseScopes(scopes)
+
+	config.DB.Exec(`UPDATE PersonalAccessToken SET lastUsedAt = NOW() WHERE id = ?`, user.TokenId)
+
+	return &user, nil
+}
@@ -0,0 +1,110 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/utils"
+)
+
+// Extract the value a rule limits on, an empty key skips the rule
+type RateLimitKey func(r *http.Request) string
+
+type RateLimitRule struct {
+	Name  string        // Unique per rule, so routes do not share buckets
+	Burst int           // Requests allowed back to back
+	Every time.Duration // Time for one request to be allowed again
+	Key   RateLimitKey
+}
+
+var rateLimitStore RateLimitStore
+
+// Select the rate limit backend. "mysql" shares limits between every instance of
+// the app, anything else keeps them in memory.
+func InitRateLimiter(backend string) {
+	if backend == "mysql" {
+		rateLimitStore = NewMySQLRateLimitStore()
+	} else {
+		rateLimitStore = NewMemoryRateLimitStore()
+	}
+
+	log.Println("Rate limiter initialized with backend:", backend)
+}
+
+// Limit requests by the client's IP address
+func ByIP(r *http.Request) string {
+	return utils.ClientIP(r)
+}
+
+// Limit requests by the email in the JSON body. The key is hashed, so any email fits the store.
+func ByEmail(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return ""
+	}
+
+	// Put the body back for the handler
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	var payload struct {
+		Email string `json:"email"`
+	}
+
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return ""
+	}
+
+	if payload.Email == "" {
+		return ""
+	}
+
+	return utils.HashToken(utils.CanonicalEmail(payload.Email))
+}
+
+// Limit requests by the authenticated user, needs to run inside Auth
+func ByUser(r *http.Request) string {
+	user, ok := r.Context().Value(UserContext).(*UserAuthDetails)
+	if !ok || user == nil {
+		return ""
+	}
+
+	return user.Id
+}
+
+func RateLimit(next http.Handler, rules ...RateLimitRule) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rateLimitStore == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		for _, rule := range rules {
+			key := rule.Key(r)
+			if key == "" {
+				continue
+			}
+
+			allowed, retryAfter, err := rateLimitStore.Take(r.Context(), rule.Name+":"+key, rule.Every, rule.Burst)
+			if err != nil {
+				// Do not lock everybody out when the store is down
+				log.Printf("Rate limit store error for %s: %v", rule.Name, err)
+				continue
+			}
+
+			if !allowed {
+				utils.TooManyRequests(w, "Too many requests. Please try again later", retryAfter)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,162 @@
+package middlewares
+
+import (
+	"context"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/config"
+)
+
+// Backend that keeps the token buckets
+type RateLimitStore interface {
+	// Take one token from the bucket, refilled at one token per `every` up to `burst`.
+	// When no token is left it returns how long to wait for the next one.
+	Take(ctx context.Context, key string, every time.Duration, burst int) (bool, time.Duration, error)
+}
+
+// Refill a bucket and try to take a token from it
+func takeToken(tokens float64, elapsed, every time.Duration, burst int) (float64, bool, time.Duration) {
+	tokens = math.Min(float64(burst), tokens+elapsed.Seconds()/every.Seconds())
+
+	if tokens >= 1 {
+		return tokens - 1, true, 0
+	}
+
+	retryAfter := time.Duration((1 - tokens) * float64(every))
+	return tokens, false, retryAfter
+}
+
+// Buckets that have been full for this long are dropped
+const bucketIdleTimeout = 24 * time.Hour
+
+type memoryBucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// In-memory store, only limits requests reaching this instance
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	store := &MemoryRateLimitStore{
+		buckets: make(map[string]*memoryBucket),
+	}
+
+	go store.cleanup()
+
+	return store
+}
+
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, every time.Duration, burst int) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{tokens: float64(burst), updatedAt: now}
+		s.buckets[key] = bucket
+	}
+
+	tokens, allowed, retryAfter := takeToken(bucket.tokens, now.Sub(bucket.updatedAt), every, burst)
+	bucket.tokens = tokens
+	bucket.updatedAt = now
+
+	return allowed, retryAfter, nil
+}
+
+func (s *MemoryRateLimitStore) cleanup() {
+	for range time.Tick(time.Hour) {
+		s.mu.Lock()
+		for key, bucket := range s.buckets {
+			if time.Since(bucket.updatedAt) > bucketIdleTimeout {
+				delete(s.buckets, key)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// MySQL backed store, shared by every instance of the app
+type MySQLRateLimitStore struct{}
+
+func NewMySQLRateLimitStore() *MySQLRateLimitStore {
+	store := &MySQLRateLimitStore{}
+
+	go store.cleanup()
+
+	return store
+}
+
+func (s *MySQLRateLimitStore) Take(ctx context.Context, key string, every time.Duration, burst int) (bool, time.Duration, error) {
+	tx, err := config.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		`
+			INSERT IGNORE INTO RateLimitBucket (bucketKey, tokens, updatedAt)
+			VALUES (?, ?, NOW(6))
+		`, key, burst,
+	)
+	if err != nil {
+		return false, 0, err
+	}
+
+	var tokens float64
+	var elapsedMicroseconds int64
+
+	err = tx.QueryRowContext(ctx,
+		`
+			SELECT tokens, TIMESTAMPDIFF(MICROSECOND, updatedAt, NOW(6))
+			FROM RateLimitBucket
+			WHERE bucketKey = ?
+			FOR UPDATE
+		`, key,
+	).Scan(&tokens, &elapsedMicroseconds)
+	if err != nil {
+		return false, 0, err
+	}
+
+	tokens, allowed, retryAfter := takeToken(tokens, time.Duration(elapsedMicroseconds)*time.Microsecond, every, burst)
+
+	_, err = tx.ExecContext(ctx,
+		`
+			UPDATE RateLimitBucket
+			SET tokens = ?, updatedAt = NOW(6)
+			WHERE bucketKey = ?
+		`, tokens, key,
+	)
+	if err != nil {
+		return false, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, 0, err
+	}
+
+	return allowed, retryAfter, nil
+}
+
+func (s *MySQLRateLimitStore) cleanup() {
+	for range time.Tick(time.Hour) {
+		_, err := config.DB.Exec(
+			`
+				DELETE FROM RateLimitBucket
+				WHERE updatedAt < NOW(6) - INTERVAL ? SECOND
+			`, int(bucketIdleTimeout.Seconds()),
+		)
+		if err != nil {
+			log.Printf("Failed to clean up rate limit buckets: %v", err)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package middlewares
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTakeToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		tokens     float64
+		elapsed    time.Duration
+		wantTokens float64
+		wantOK     bool
+		wantRetry  time.Duration
+	}{
+		{"full bucket", 5, 0, 4, true, 0},
+		{"empty bucket", 0, 0, 0, false, 10 * time.Second},
+		{"half refilled", 0, 5 * time.Second, 0.5, false, 5 * time.Second},
+		{"refilled one token", 0, 10 * time.Second, 0, true, 0},
+		{"refill stops at burst", 4, time.Hour, 4, true, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokens, ok, retryAfter := takeToken(test.tokens, test.elapsed, 10*time.Second, 5)
+			if tokens != test.wantTokens || ok != test.wantOK || retryAfter != test.wantRetry {
+				t.Errorf("takeToken() = (%v, %v, %v), want (%v, %v, %v)", tokens, ok, retryAfter, test.wantTokens, test.wantOK, test.wantRetry)
+			}
+		})
+	}
+}
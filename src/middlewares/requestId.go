@@ -0,0 +1,36 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	"github.com/google/uuid"
+)
+
+var RequestIDContext = &struct{}{}
+
+// Request ids passed in by a proxy are kept when they look sane
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Give every request an id, taken from X-Request-ID when present, and echo it in the response
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId := r.Header.Get("X-Request-ID")
+		if !validRequestID.MatchString(requestId) {
+			requestId = uuid.New().String()
+		}
+
+		w.Header().Set("X-Request-ID", requestId)
+
+		ctx := context.WithValue(r.Context(), RequestIDContext, requestId)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Id given to the request by RequestID
+func GetRequestID(r *http.Request) string {
+	requestId, _ := r.Context().Value(RequestIDContext).(string)
+	return requestId
+}
@@ -0,0 +1,37 @@
+package middlewares
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/utils"
+)
+
+const (
+	RoleReader = "reader"
+	RoleAuthor = "author"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+// Only let users with one of the roles through, needs to run inside Auth
+func RequireRole(next http.Handler, roles ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(UserContext).(*UserAuthDetails)
+		if !ok || user == nil {
+			utils.UnAuthorized(w, "User is not Authenticated")
+			return
+		}
+
+		if !slices.Contains(roles, user.Role) {
+			if user.Role == RoleReader && slices.Contains(roles, RoleAuthor) {
+				utils.Forbidden(w, "Readers cannot write blogs. Ask an admin to make you an author")
+				return
+			}
+			utils.Forbidden(w, "You do not have permission to perform this action")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
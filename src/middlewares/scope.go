@@ -0,0 +1,42 @@
+package middlewares
+
+import (
+	"slices"
+	"strings"
+)
+
+// What a personal access token may be used for
+const (
+	ScopeBlogRead  = "blog:read"
+	ScopeBlogWrite = "blog:write"
+)
+
+// Every scope a personal access token can be created with
+var Scopes = []string{ScopeBlogRead, ScopeBlogWrite}
+
+// Personal access tokens start with this, so Auth can tell them apart from JWTs
+const PersonalAccessTokenPrefix = "bat_"
+
+// Whether the request was allowed everything listed in scopes. Session logins are not scoped.
+func (u *UserAuthDetails) HasScopes(scopes ...string) bool {
+	if u.TokenId == "" {
+		return true
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(u.Scopes, scope) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Split scopes stored as a comma separated column
+func ParseScopes(value string) []string {
+	if value == "" {
+		return []string{}
+	}
+
+	return strings.Split(value, ",")
+}
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/controllers"
+	"github.com/Sahil2k07/Blog-App-Go/src/middlewares"
+)
+
+func AdminRoutes(router *http.ServeMux) {
+
+	// Admin only Routes
+	router.Handle("/admin/users", middlewares.Auth(middlewares.RequireRole(http.HandlerFunc(controllers.ListUsers), middlewares.RoleAdmin)))
+	router.Handle("/admin/users/{id}/role", middlewares.Auth(middlewares.RequireRole(http.HandlerFunc(controllers.UpdateUserRole), middlewares.RoleAdmin)))
+	router.Handle("/admin/blogs/{id}/unpublish", middlewares.Auth(middlewares.RequireRole(http.HandlerFunc(controllers.UnpublishBlog), middlewares.RoleEditor, middlewares.RoleAdmin)))
+	router.Handle("/admin/metrics/bloom", middlewares.Auth(middlewares.RequireRole(http.HandlerFunc(controllers.GetBloomMetrics), middlewares.RoleAdmin)))
+	router.Handle("/admin/audit-events", middlewares.Auth(middlewares.RequireRole(http.HandlerFunc(controllers.ListAuditEvents), middlewares.RoleAdmin)))
+	router.Handle("/admin/invites", middlewares.Auth(middlewares.RequireRole(http.HandlerFunc(controllers.Invites), middlewares.RoleAdmin)))
+	router.Handle("/admin/invites/{id}", middlewares.Auth(middlewares.RequireRole(http.HandlerFunc(controllers.RevokeInvite), middlewares.RoleAdmin)))
+	router.Handle("/admin/blogs/{id}", middlewares.Auth(middlewares.RequireRole(http.HandlerFunc(controllers.AdminDeleteBlog), middlewares.RoleAdmin)))
+
+}
@@ -4,12 +4,24 @@ import (
 	"net/http"
 
 	"github.com/Sahil2k07/Blog-App-Go/src/controllers"
+	"github.com/Sahil2k07/Blog-App-Go/src/middlewares"
 )
 
 func AuthRoutes(router *http.ServeMux) {
 
-	router.HandleFunc("/auth/resend-otp", controllers.ReSendOtp)
-	router.HandleFunc("/auth/signup", controllers.SignUp)
-	router.HandleFunc("/auth/verify-user", controllers.VerifyUser)
+	router.Handle("/auth/challenge", middlewares.RateLimit(http.HandlerFunc(controllers.GetChallenge), challengeLimits...))
+	router.Handle("/auth/resend-otp", middlewares.RateLimit(http.HandlerFunc(controllers.ReSendOtp), otpLimits...))
+	router.Handle("/auth/signup", middlewares.RateLimit(http.HandlerFunc(controllers.SignUp), signupLimits...))
+	router.Handle("/auth/verify-user", middlewares.RateLimit(http.HandlerFunc(controllers.VerifyUser), otpLimits...))
+	router.Handle("/auth/refresh", middlewares.RateLimit(http.HandlerFunc(controllers.RefreshToken), refreshLimits...))
+	router.Handle("/auth/forgot-password", middlewares.RateLimit(http.HandlerFunc(controllers.ForgotPassword), passwordResetLimits...))
+	router.Handle("/auth/reset-password", middlewares.RateLimit(http.HandlerFunc(controllers.ResetPassword), passwordResetLimits...))
+	router.Handle("/auth/restore-account", middlewares.RateLimit(http.HandlerFunc(controllers.RestoreAccount), loginLimits...))
+	router.Handle("/auth/magic-link", middlewares.RateLimit(http.HandlerFunc(controllers.RequestMagicLink), passwordResetLimits...))
+	router.Handle("/auth/magic-link/consume", middlewares.RateLimit(http.HandlerFunc(controllers.MagicLinkLogin), twoFactorLimits...))
+	router.HandleFunc("/magic-link", controllers.MagicLinkPage)
+	router.Handle("/auth/oauth/{provider}", middlewares.RateLimit(http.HandlerFunc(controllers.OAuthLogin), oauthLimits...))
+	router.Handle("/auth/oauth/{provider}/callback", middlewares.RateLimit(http.HandlerFunc(controllers.OAuthCallback), oauthLimits...))
+	router.HandleFunc("/oauth/result", controllers.OAuthResultPage)
 
 }
@@ -7,15 +7,18 @@ import (
 	"github.com/Sahil2k07/Blog-App-Go/src/middlewares"
 )
 
+// Roles allowed to write blogs
+var writerRoles = []string{middlewares.RoleAuthor, middlewares.RoleEditor, middlewares.RoleAdmin}
+
 func BlogRoutes(router *http.ServeMux) {
 
 	router.HandleFunc("/blog/get-blog/{id}", controllers.GetBlog)
 	router.HandleFunc("/blog/get-blogs", controllers.GetAllBlogs)
 
-	// Authenticated Routes
-	router.Handle("/blog/user-blogs", middlewares.Auth(http.HandlerFunc(controllers.GetUserBlogs)))
-	router.Handle("/blog/update-blog/{id}", middlewares.Auth(http.HandlerFunc(controllers.UpdateBlog)))
-	router.Handle("/blog/delete-blog/{id}", middlewares.Auth(http.HandlerFunc(controllers.DeleteBlog)))
-	router.Handle("/blog/create-blog", middlewares.Auth(http.HandlerFunc(controllers.CreateBlog)))
+	// Authenticated Routes, personal access tokens need the listed scope
+	router.Handle("/blog/user-blogs", middlewares.Auth(http.HandlerFunc(controllers.GetUserBlogs), middlewares.ScopeBlogRead))
+	router.Handle("/blog/update-blog/{id}", middlewares.Auth(middlewares.RequireRole(middlewares.RateLimit(http.HandlerFunc(controllers.UpdateBlog), writeLimits...), writerRoles...), middlewares.ScopeBlogWrite))
+	router.Handle("/blog/delete-blog/{id}", middlewares.Auth(middlewares.RequireRole(middlewares.RateLimit(http.HandlerFunc(controllers.DeleteBlog), writeLimits...), writerRoles...), middlewares.ScopeBlogWrite))
+	router.Handle("/blog/create-blog", middlewares.Auth(middlewares.RequireRole(middlewares.RateLimit(http.HandlerFunc(controllers.CreateBlog), writeLimits...), writerRoles...), middlewares.ScopeBlogWrite))
 
 }
@@ -0,0 +1,59 @@
+package routes
+
+import (
+	"time"
+
+	"github.com/Sahil2k07/Blog-App-Go/src/middlewares"
+)
+
+// Rate limits for each route, a rule allows Burst requests at once and one more every Every
+var (
+	loginLimits = []middlewares.RateLimitRule{
+		{Name: "login-ip", Burst: 10, Every: 30 * time.Second, Key: middlewares.ByIP},
+		{Name: "login-email", Burst: 5, Every: time.Minute, Key: middlewares.ByEmail},
+	}
+
+	signupLimits = []middlewares.RateLimitRule{
+		{Name: "signup-ip", Burst: 5, Every: 10 * time.Minute, Key: middlewares.ByIP},
+		{Name: "signup-email", Burst: 2, Every: 10 * time.Minute, Key: middlewares.ByEmail},
+	}
+
+	challengeLimits = []middlewares.RateLimitRule{
+		{Name: "challenge-ip", Burst: 20, Every: time.Minute, Key: middlewares.ByIP},
+	}
+
+	otpLimits = []middlewares.RateLimitRule{
+		{Name: "otp-ip", Burst: 10, Every: time.Minute, Key: middlewares.ByIP},
+		{Name: "otp-email", Burst: 5, Every: 5 * time.Minute, Key: middlewares.ByEmail},
+	}
+
+	passwordResetLimits = []middlewares.RateLimitRule{
+		{Name: "password-reset-ip", Burst: 5, Every: 5 * time.Minute, Key: middlewares.ByIP},
+		{Name: "password-reset-email", Burst: 3, Every: 10 * time.Minute, Key: middlewares.ByEmail},
+	}
+
+	twoFactorLimits = []middlewares.RateLimitRule{
+		{Name: "2fa-ip", Burst: 5, Every: time.Minute, Key: middlewares.ByIP},
+	}
+
+	oauthLimits = []middlewares.RateLimitRule{
+		{Name: "oauth-ip", Burst: 20, Every: 30 * time.Second, Key: middlewares.ByIP},
+	}
+
+	refreshLimits = []middlewares.RateLimitRule{
+		{Name: "refresh-ip", Burst: 30, Every: 10 * time.Second, Key: middlewares.ByIP},
+	}
+
+	emailChangeLimits = []middlewares.RateLimitRule{
+		{Name: "email-change-ip", Burst: 10, Every: time.Minute, Key: middlewares.ByIP},
+		{Name: "email-change-user", Burst: 5, Every: 5 * time.Minute, Key: middlewares.ByUser},
+	}
+
+	exportLimits = []middlewares.RateLimitRule{
+		{Name: "export-user", Burst: 3, Every: 20 * time.Minute, Key: middlewares.ByUser},
+	}
+
+	writeLimits = []middlewares.RateLimitRule{
+		{Name: "write-user", Burst: 20, Every: 6 * time.Second, Key: middlewares.ByUser},
+	}
+)
@@ -11,6 +11,7 @@ func AppRoutes() *http.ServeMux {
 	router := http.NewServeMux()
 
 	router.HandleFunc("/", controllers.RootHandler)
+	router.HandleFunc("/.well-known/jwks.json", controllers.GetJWKS)
 
 	// Register all route handlers
 	AuthRoutes(router)
@@ -19,6 +20,8 @@ func AppRoutes() *http.ServeMux {
 
 	BlogRoutes(router)
 
+	AdminRoutes(router)
+
 	return router
 
 }
@@ -9,10 +9,28 @@ import (
 
 func UserRoutes(router *http.ServeMux) {
 
-	router.HandleFunc("/user/login", controllers.Login)
+	router.HandleFunc("/user/export/download/{id}", controllers.DownloadDataExport)
+	router.Handle("/user/login", middlewares.RateLimit(http.HandlerFunc(controllers.Login), loginLimits...))
+	router.Handle("/user/2fa/verify", middlewares.RateLimit(http.HandlerFunc(controllers.VerifyTwoFactor), twoFactorLimits...))
 
 	// Authenticated Routes
-	router.Handle("/user/update-profile", middlewares.Auth(http.HandlerFunc(controllers.UpdateProfile)))
+	router.Handle("/user/update-profile", middlewares.Auth(middlewares.RateLimit(http.HandlerFunc(controllers.UpdateProfile), writeLimits...)))
 	router.Handle("/user/get-profile", middlewares.Auth(http.HandlerFunc(controllers.GetProfile)))
+	router.Handle("/user/logout", middlewares.Auth(http.HandlerFunc(controllers.Logout)))
+	router.Handle("/user/logout-all", middlewares.Auth(http.HandlerFunc(controllers.LogoutAll)))
+	router.Handle("/user/sessions", middlewares.Auth(http.HandlerFunc(controllers.ListSessions)))
+	router.Handle("/user/sessions/{id}", middlewares.Auth(http.HandlerFunc(controllers.RevokeSession)))
+	router.Handle("/user/change-password", middlewares.Auth(middlewares.RateLimit(http.HandlerFunc(controllers.ChangePassword), passwordResetLimits...)))
+	router.Handle("/user/change-email", middlewares.Auth(middlewares.RateLimit(http.HandlerFunc(controllers.ChangeEmail), emailChangeLimits...)))
+	router.Handle("/user/change-email/confirm", middlewares.Auth(middlewares.RateLimit(http.HandlerFunc(controllers.ConfirmEmailChange), emailChangeLimits...)))
+	router.Handle("/user/account", middlewares.Auth(middlewares.RateLimit(http.HandlerFunc(controllers.DeleteAccount), passwordResetLimits...)))
+	router.Handle("/user/export", middlewares.Auth(middlewares.RateLimit(http.HandlerFunc(controllers.ExportData), exportLimits...)))
+	router.Handle("/user/export/{id}", middlewares.Auth(http.HandlerFunc(controllers.GetDataExport)))
+	router.Handle("/user/tokens", middlewares.Auth(middlewares.RateLimit(http.HandlerFunc(controllers.PersonalAccessTokens), writeLimits...)))
+	router.Handle("/user/tokens/{id}", middlewares.Auth(http.HandlerFunc(controllers.RevokePersonalAccessToken)))
+	router.Handle("/user/2fa/enroll", middlewares.Auth(http.HandlerFunc(controllers.EnrollTwoFactor)))
+	router.Handle("/user/2fa/confirm", middlewares.Auth(middlewares.RateLimit(http.HandlerFunc(controllers.ConfirmTwoFactor), twoFactorLimits...)))
+	router.Handle("/user/2fa/recovery-codes", middlewares.Auth(middlewares.RateLimit(http.HandlerFunc(controllers.RegenerateRecoveryCodes), twoFactorLimits...)))
+	router.Handle("/user/2fa/disable", middlewares.Auth(middlewares.RateLimit(http.HandlerFunc(controllers.DisableTwoFactor), twoFactorLimits...)))
 
 }
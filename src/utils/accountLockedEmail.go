@@ -0,0 +1,13 @@
+package utils
+
+import "fmt"
+
+func AccountLockedEmail(lockout string) string {
+	return EmailLayout("Account Locked", fmt.Sprintf(`
+					<p>Dear User,</p>
+					<p>There were too many failed attempts to login to your Blog App account, so logins are blocked for the
+						next %s.</p>
+					<p>If this was you, just wait and try again, or reset your password. If it was not, your password is
+						still safe, but consider changing it to something only you know.</p>
+	`, lockout))
+}
@@ -3,95 +3,12 @@ package utils
 import "fmt"
 
 func AuthEmail(otp string) string {
-	return fmt.Sprintf(`
-		<!DOCTYPE html>
-		<html>
-		
-		<head>
-			<meta charset="UTF-8">
-			<title>OTP Verification Email</title>
-			<style>
-				body {
-					background-color: #ffffff;
-					font-family: Arial, sans-serif;
-					font-size: 16px;
-					line-height: 1.4;
-					color: #333333;
-					margin: 0;
-					padding: 0;
-				}
-		
-				.container {
-					max-width: 600px;
-					margin: 0 auto;
-					padding: 20px;
-					text-align: center;
-				}
-		
-				.logo {
-					margin-bottom: 20px;
-					color: white;
-					background-image: linear-gradient(to right, #3457D5 , #00CED1);
-					max-width: max-content;
-					margin-left: auto;
-					margin-right: auto;
-					border-radius: 10px;
-					padding: 10px;
-				}
-		
-				.message {
-					font-size: 18px;
-					font-weight: bold;
-					margin-bottom: 20px;
-				}
-		
-				.body {
-					font-size: 16px;
-					margin-bottom: 20px;
-				}
-		
-				.cta {
-					display: inline-block;
-					padding: 10px 20px;
-					background-color: #FFD60A;
-					color: #000000;
-					text-decoration: none;
-					border-radius: 5px;
-					font-size: 16px;
-					font-weight: bold;
-					margin-top: 20px;
-				}
-		
-				.support {
-					font-size: 14px;
-					color: #999999;
-					margin-top: 20px;
-				}
-		
-				.highlight {
-					font-weight: bold;
-				}
-			</style>
-		
-		</head>
-		
-		<body>
-			<div class="container">
-				<h1 class="logo">Blog-App</h1>
-				<div class="message">OTP Verification Email</div>
-				<div class="body">
+	return EmailLayout("OTP Verification Email", fmt.Sprintf(`
 					<p>Dear User,</p>
 					<p>Thank you for registering with Blog App. To complete your registration, please use the following OTP
 						(One-Time Password) to verify your account:</p>
 					<h2 class="highlight">%s</h2>
-					<p>This OTP is valid for 5 minutes. If you did not request this verification, please disregard this email.
+					<p>This OTP is valid for %d minutes. If you did not request this verification, please disregard this email.
 					Once your account is verified, you will have access to our platform and its features.</p>
-				</div>
-				<div class="support">If you have any questions or need assistance, please feel free to reach out to us at
-					Blog-App.com. We are here to help!</div>
-			</div>
-		</body>
-		
-		</html>
-	`, otp)
+	`, otp, int(OtpTTL().Minutes())))
 }
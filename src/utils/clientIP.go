@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Number of proxies in front of the app that append to X-Forwarded-For, TRUSTED_PROXY_HOPS
+func TrustedProxyHops() int {
+	return max(GetEnvInt("TRUSTED_PROXY_HOPS", 1), 1)
+}
+
+// IP address of the client that made the request.
+// X-Forwarded-For is only trusted when running behind a proxy (TRUST_PROXY=true), and only
+// the entries our own proxies appended, counted from the right. Anything left of them was
+// sent by the client, which could pick its own address.
+func ClientIP(r *http.Request) string {
+	if os.Getenv("TRUST_PROXY") == "true" {
+		if forwardedFor := r.Header.Values("X-Forwarded-For"); len(forwardedFor) > 0 {
+			entries := strings.Split(strings.Join(forwardedFor, ","), ",")
+
+			entry := max(len(entries)-TrustedProxyHops(), 0)
+			if ip := strings.TrimSpace(entries[entry]); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
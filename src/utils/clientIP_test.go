@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name         string
+		trustProxy   string
+		hops         string
+		forwardedFor []string
+		want         string
+	}{
+		{"proxy not trusted", "false", "1", []string{"203.0.113.9"}, "192.0.2.1"},
+		{"no header", "true", "1", nil, "192.0.2.1"},
+		{"one proxy", "true", "1", []string{"203.0.113.9"}, "203.0.113.9"},
+		{"spoofed entries are skipped", "true", "1", []string{"1.1.1.1, 2.2.2.2, 203.0.113.9"}, "203.0.113.9"},
+		{"two proxies", "true", "2", []string{"1.1.1.1, 203.0.113.9, 10.0.0.2"}, "203.0.113.9"},
+		{"header sent twice", "true", "1", []string{"1.1.1.1", "203.0.113.9"}, "203.0.113.9"},
+		{"fewer entries than hops", "true", "3", []string{"203.0.113.9"}, "203.0.113.9"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("TRUST_PROXY", test.trustProxy)
+			t.Setenv("TRUSTED_PROXY_HOPS", test.hops)
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = "192.0.2.1:4321"
+			for _, value := range test.forwardedFor {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+
+			if got := ClientIP(r); got != test.want {
+				t.Errorf("ClientIP() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
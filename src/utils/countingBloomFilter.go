@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+// A Bloom filter with a counter instead of a bit per slot, so items can be removed again.
+// Counters stop at 255 and are never decremented from there, which can only cause false positives.
+type CountingBloomFilter struct {
+	m        uint
+	k        uint
+	counters []uint8
+	items    uint // Items currently added, to estimate the false positive rate
+}
+
+const maxBloomCounter = math.MaxUint8
+
+// Size a filter for n items at the given false positive rate
+func NewCountingBloomFilter(n uint, falsePositiveRate float64) *CountingBloomFilter {
+	m, k := bloom.EstimateParameters(n, falsePositiveRate)
+
+	return &CountingBloomFilter{m: m, k: k, counters: make([]uint8, m)}
+}
+
+func (f *CountingBloomFilter) slots(data []byte) []uint64 {
+	slots := bloom.Locations(data, f.k)
+	for i := range slots {
+		slots[i] %= uint64(f.m)
+	}
+
+	return slots
+}
+
+func (f *CountingBloomFilter) Add(data []byte) {
+	for _, slot := range f.slots(data) {
+		if f.counters[slot] < maxBloomCounter {
+			f.counters[slot]++
+		}
+	}
+	f.items++
+}
+
+// Whether data may have been added. False means it certainly was not.
+func (f *CountingBloomFilter) Test(data []byte) bool {
+	for _, slot := range f.slots(data) {
+		if f.counters[slot] == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Take back one Add of data. Only call it for items that were added, anything else
+// would decrement the counters of other items. Items that test negative are ignored.
+func (f *CountingBloomFilter) Remove(data []byte) bool {
+	slots := f.slots(data)
+
+	for _, slot := range slots {
+		if f.counters[slot] == 0 {
+			return false
+		}
+	}
+
+	for _, slot := range slots {
+		if f.counters[slot] < maxBloomCounter {
+			f.counters[slot]--
+		}
+	}
+	if f.items > 0 {
+		f.items--
+	}
+
+	return true
+}
+
+func (f *CountingBloomFilter) AddString(data string) {
+	f.Add([]byte(data))
+}
+
+func (f *CountingBloomFilter) TestString(data string) bool {
+	return f.Test([]byte(data))
+}
+
+func (f *CountingBloomFilter) RemoveString(data string) bool {
+	return f.Remove([]byte(data))
+}
+
+// Number of items currently in the filter
+func (f *CountingBloomFilter) Items() uint {
+	return f.items
+}
+
+// Expected false positive rate with the items currently in the filter
+func (f *CountingBloomFilter) EstimatedFalsePositiveRate() float64 {
+	return math.Pow(1-math.Exp(-float64(f.k)*float64(f.items)/float64(f.m)), float64(f.k))
+}
+
+// Write the filter, to be read back with ReadFrom
+func (f *CountingBloomFilter) WriteTo(w io.Writer) (int64, error) {
+	header := []uint64{uint64(f.m), uint64(f.k), uint64(f.items)}
+	if err := binary.Write(w, binary.BigEndian, header); err != nil {
+		return 0, err
+	}
+
+	n, err := w.Write(f.counters)
+
+	return int64(len(header)*8 + n), err
+}
+
+// Replace the filter with one written by WriteTo
+func (f *CountingBloomFilter) ReadFrom(r io.Reader) (int64, error) {
+	header := make([]uint64, 3)
+	if err := binary.Read(r, binary.BigEndian, header); err != nil {
+		return 0, err
+	}
+
+	m, k, items := uint(header[0]), uint(header[1]), uint(header[2])
+	if m == 0 || k == 0 {
+		return 0, errors.New("invalid counting bloom filter")
+	}
+
+	counters := make([]uint8, m)
+	n, err := io.ReadFull(r, counters)
+	if err != nil {
+		return int64(len(header)*8 + n), err
+	}
+
+	f.m, f.k, f.items, f.counters = m, k, items, counters
+
+	return int64(len(header)*8 + n), nil
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestCountingBloomFilterAddRemove(t *testing.T) {
+	filter := NewCountingBloomFilter(1000, 0.01)
+
+	for i := 0; i < 100; i++ {
+		filter.AddString(fmt.Sprintf("user%d@example.com", i))
+	}
+
+	for i := 0; i < 100; i++ {
+		if !filter.TestString(fmt.Sprintf("user%d@example.com", i)) {
+			t.Fatalf("TestString() = false for an added item %d", i)
+		}
+	}
+
+	if filter.Items() != 100 {
+		t.Errorf("Items() = %d, want 100", filter.Items())
+	}
+
+	for i := 0; i < 50; i++ {
+		if !filter.RemoveString(fmt.Sprintf("user%d@example.com", i)) {
+			t.Errorf("RemoveString() = false for an added item %d", i)
+		}
+	}
+
+	// Removing must never take out items that are still in the filter
+	for i := 50; i < 100; i++ {
+		if !filter.TestString(fmt.Sprintf("user%d@example.com", i)) {
+			t.Fatalf("TestString() = false for item %d after removing others", i)
+		}
+	}
+
+	if filter.Items() != 50 {
+		t.Errorf("Items() = %d, want 50", filter.Items())
+	}
+}
+
+func TestCountingBloomFilterDuplicates(t *testing.T) {
+	filter := NewCountingBloomFilter(1000, 0.01)
+
+	filter.AddString("same@example.com")
+	filter.AddString("same@example.com")
+	filter.RemoveString("same@example.com")
+
+	if !filter.TestString("same@example.com") {
+		t.Error("TestString() = false while one add is left")
+	}
+
+	filter.RemoveString("same@example.com")
+
+	if filter.TestString("same@example.com") {
+		t.Error("TestString() = true after every add was removed")
+	}
+	if filter.RemoveString("same@example.com") {
+		t.Error("RemoveString() = true for an item not in the filter")
+	}
+}
+
+func TestCountingBloomFilterSaturatedCounter(t *testing.T) {
+	filter := NewCountingBloomFilter(10, 0.01)
+
+	for i := 0; i < maxBloomCounter+10; i++ {
+		filter.AddString("busy@example.com")
+	}
+	for i := 0; i < maxBloomCounter+10; i++ {
+		filter.RemoveString("busy@example.com")
+	}
+
+	// Saturated counters are never decremented, so only false positives can come out of it
+	if !filter.TestString("busy@example.com") {
+		t.Error("TestString() = false after decrementing saturated counters")
+	}
+}
+
+func TestCountingBloomFilterRoundTrip(t *testing.T) {
+	filter := NewCountingBloomFilter(1000, 0.01)
+	filter.AddString("saved@example.com")
+
+	var buffer bytes.Buffer
+	if _, err := filter.WriteTo(&buffer); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+
+	loaded := &CountingBloomFilter{}
+	if _, err := loaded.ReadFrom(&buffer); err != nil {
+		t.Fatalf("ReadFrom() error = %v", err)
+	}
+
+	if !loaded.TestString("saved@example.com") || loaded.Items() != 1 {
+		t.Error("ReadFrom() did not restore the filter written by WriteTo()")
+	}
+}
@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrEmailDomainNotAllowed = errors.New("Emails from this domain are not allowed")
+	ErrDisposableEmail       = errors.New("Disposable email addresses are not allowed")
+)
+
+//go:embed disposableDomains.txt
+var disposableDomainsList string
+
+// Rules of providers that deliver several spellings of an address to the same mailbox
+type emailProviderRule struct {
+	ignoreDots bool   // a.b@ and ab@ are the same mailbox
+	plusTags   bool   // Everything after a + is dropped
+	domain     string // Alias domains point to this one
+}
+
+var emailProviderRules = map[string]emailProviderRule{
+	"gmail.com":      {ignoreDots: true, plusTags: true, domain: "gmail.com"},
+	"googlemail.com": {ignoreDots: true, plusTags: true, domain: "gmail.com"},
+	"outlook.com":    {plusTags: true},
+	"hotmail.com":    {plusTags: true},
+	"live.com":       {plusTags: true},
+	"icloud.com":     {plusTags: true},
+	"proton.me":      {plusTags: true},
+	"protonmail.com": {plusTags: true},
+	"fastmail.com":   {plusTags: true},
+}
+
+// Bump whenever emailProviderRules change, so stored canonical emails are recomputed
+const emailProviderRulesVersion = 1
+
+// Lowercase and trim an email. Applied to every email that comes in, before it is stored or looked up.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Form of an email used to tell whether two addresses are the same mailbox. With
+// EMAIL_PROVIDER_RULES on, provider rules like Gmail's dots and plus-tags are applied too.
+func CanonicalEmail(email string) string {
+	email = NormalizeEmail(email)
+
+	if os.Getenv("EMAIL_PROVIDER_RULES") != "true" {
+		return email
+	}
+
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+
+	local, domain := email[:at], email[at+1:]
+
+	rule, ok := emailProviderRules[domain]
+	if !ok {
+		return email
+	}
+
+	if rule.plusTags {
+		if plus := strings.Index(local, "+"); plus > 0 {
+			local = local[:plus]
+		}
+	}
+	if rule.ignoreDots {
+		local = strings.ReplaceAll(local, ".", "")
+	}
+	if rule.domain != "" {
+		domain = rule.domain
+	}
+
+	return local + "@" + domain
+}
+
+// Rules CanonicalEmail currently applies. Rows stored under another version are recomputed at startup.
+func CanonicalEmailVersion() string {
+	if os.Getenv("EMAIL_PROVIDER_RULES") != "true" {
+		return "lowercase"
+	}
+
+	return fmt.Sprintf("providers-%d", emailProviderRulesVersion)
+}
+
+// Parse a comma separated list of domains
+func emailDomainList(value string) map[string]bool {
+	domains := map[string]bool{}
+
+	for _, domain := range strings.Split(value, ",") {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			domains[domain] = true
+		}
+	}
+
+	return domains
+}
+
+var disposableDomains = sync.OnceValue(func() map[string]bool {
+	domains := map[string]bool{}
+
+	scanner := bufio.NewScanner(strings.NewReader(disposableDomainsList))
+	for scanner.Scan() {
+		line := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if line != "" && !strings.HasPrefix(line, "#") {
+			domains[line] = true
+		}
+	}
+
+	return domains
+})
+
+// Whether the domain or one of its parent domains is in the list
+func matchesEmailDomain(domain string, domains map[string]bool) bool {
+	for {
+		if domains[domain] {
+			return true
+		}
+
+		dot := strings.Index(domain, ".")
+		if dot < 0 {
+			return false
+		}
+		domain = domain[dot+1:]
+	}
+}
+
+// Check an email against the signup domain policy: EMAIL_ALLOWED_DOMAINS when set,
+// EMAIL_DENIED_DOMAINS, and the bundled list of disposable domains unless
+// EMAIL_BLOCK_DISPOSABLE is false. Meant to run before any OTP is sent to a new address.
+func CheckEmailDomain(email string) error {
+	email = NormalizeEmail(email)
+	domain := email[strings.LastIndex(email, "@")+1:]
+
+	if allowed := emailDomainList(os.Getenv("EMAIL_ALLOWED_DOMAINS")); len(allowed) > 0 && !matchesEmailDomain(domain, allowed) {
+		return ErrEmailDomainNotAllowed
+	}
+
+	if matchesEmailDomain(domain, emailDomainList(os.Getenv("EMAIL_DENIED_DOMAINS"))) {
+		return ErrEmailDomainNotAllowed
+	}
+
+	if os.Getenv("EMAIL_BLOCK_DISPOSABLE") != "false" && matchesEmailDomain(domain, disposableDomains()) {
+		return ErrDisposableEmail
+	}
+
+	return nil
+}
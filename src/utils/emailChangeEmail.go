@@ -0,0 +1,22 @@
+package utils
+
+import "fmt"
+
+func ChangeEmailOtpEmail(otp string) string {
+	return EmailLayout("Confirm your new Email", fmt.Sprintf(`
+					<p>Dear User,</p>
+					<p>We received a request to move a Blog App account to this email address. Please use the following code
+						to confirm the change:</p>
+					<h2 class="highlight">%s</h2>
+					<p>This code is valid for %d minutes. If you did not request this change, please disregard this email.</p>
+	`, otp, int(OtpTTL().Minutes())))
+}
+
+func EmailChangedEmail(newEmail string) string {
+	return EmailLayout("Email Changed", fmt.Sprintf(`
+					<p>Dear User,</p>
+					<p>The email address of your Blog App account was just changed to <strong>%s</strong>. You will not
+						receive emails about this account here anymore, and all of your other sessions have been logged out.</p>
+					<p>If you made this change, you can disregard this email. If you did not, please reach out to us right away.</p>
+	`, newEmail))
+}
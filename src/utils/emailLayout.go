@@ -0,0 +1,93 @@
+package utils
+
+import "fmt"
+
+// Wrap the content of an email in the common Blog-App layout
+func EmailLayout(title, content string) string {
+	return fmt.Sprintf(`
+		<!DOCTYPE html>
+		<html>
+		
+		<head>
+			<meta charset="UTF-8">
+			<title>%s</title>
+			<style>
+				body {
+					background-color: #ffffff;
+					font-family: Arial, sans-serif;
+					font-size: 16px;
+					line-height: 1.4;
+					color: #333333;
+					margin: 0;
+					padding: 0;
+				}
+		
+				.container {
+					max-width: 600px;
+					margin: 0 auto;
+					padding: 20px;
+					text-align: center;
+				}
+		
+				.logo {
+					margin-bottom: 20px;
+					color: white;
+					background-image: linear-gradient(to right, #3457D5 , #00CED1);
+					max-width: max-content;
+					margin-left: auto;
+					margin-right: auto;
+					border-radius: 10px;
+					padding: 10px;
+				}
+		
+				.message {
+					font-size: 18px;
+					font-weight: bold;
+					margin-bottom: 20px;
+				}
+		
+				.body {
+					font-size: 16px;
+					margin-bottom: 20px;
+				}
+		
+				.cta {
+					display: inline-block;
+					padding: 10px 20px;
+					background-color: #FFD60A;
+					color: #000000;
+					text-decoration: none;
+					border-radius: 5px;
+					font-size: 16px;
+					font-weight: bold;
+					margin-top: 20px;
+				}
+		
+				.support {
+					font-size: 14px;
+					color: #999999;
+					margin-top: 20px;
+				}
+		
+				.highlight {
+					font-weight: bold;
+				}
+			</style>
+		
+		</head>
+		
+		<body>
+			<div class="container">
+				<h1 class="logo">Blog-App</h1>
+				<div class="message">%s</div>
+				<div class="body">
+					%s
+				</div>
+				<div class="support">If you have any questions or need assistance, please feel free to reach out to us at
+					Blog-App.com. We are here to help!</div>
+			</div>
+		</body>
+		
+		</html>
+	`, title, title, content)
+}
@@ -0,0 +1,49 @@
+package utils
+
+import "testing"
+
+func TestCanonicalEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{" John.Doe+news@Gmail.com ", "johndoe@gmail.com"},
+		{"j.o.h.n@googlemail.com", "john@gmail.com"},
+		{"jane+work@outlook.com", "jane@outlook.com"},
+		{"jane.doe@outlook.com", "jane.doe@outlook.com"},
+		{"first.last+tag@example.com", "first.last+tag@example.com"},
+		{"+only@gmail.com", "+only@gmail.com"},
+		{"no-at-sign", "no-at-sign"},
+	}
+
+	t.Setenv("EMAIL_PROVIDER_RULES", "true")
+
+	for _, test := range tests {
+		if got := CanonicalEmail(test.email); got != test.want {
+			t.Errorf("CanonicalEmail(%q) = %q, want %q", test.email, got, test.want)
+		}
+	}
+}
+
+func TestCanonicalEmailWithoutProviderRules(t *testing.T) {
+	t.Setenv("EMAIL_PROVIDER_RULES", "false")
+
+	if got := CanonicalEmail(" John.Doe+news@Gmail.com"); got != "john.doe+news@gmail.com" {
+		t.Errorf("CanonicalEmail() = %q, want only lowercased and trimmed", got)
+	}
+}
+
+func TestCanonicalEmailVersion(t *testing.T) {
+	t.Setenv("EMAIL_PROVIDER_RULES", "false")
+	without := CanonicalEmailVersion()
+
+	t.Setenv("EMAIL_PROVIDER_RULES", "true")
+	with := CanonicalEmailVersion()
+
+	if without == with {
+		t.Errorf("CanonicalEmailVersion() = %q with and without provider rules, want them to differ", with)
+	}
+	if len(with) > 20 || len(without) > 20 {
+		t.Errorf("CanonicalEmailVersion() is longer than the canonicalEmailVersion column")
+	}
+}
@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// Read an integer environment variable, falling back to the default when unset or invalid
+func GetEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+
+	return value
+}
+
+// Time a deleted account can still be restored before it is purged
+func AccountDeletionGracePeriod() time.Duration {
+	return time.Duration(GetEnvInt("ACCOUNT_DELETION_GRACE_DAYS", 30)) * 24 * time.Hour
+}
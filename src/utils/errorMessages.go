@@ -2,7 +2,10 @@ package utils
 
 import (
 	"encoding/json"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 func WrongMethod(w http.ResponseWriter) {
@@ -61,3 +64,30 @@ func InternalServerError(w http.ResponseWriter, message string) {
 
 	json.NewEncoder(w).Encode(response)
 }
+
+func TooManyRequests(w http.ResponseWriter, message string, retryAfter time.Duration) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	w.WriteHeader(http.StatusTooManyRequests)
+
+	response := map[string]interface{}{
+		"status":  429,
+		"success": false,
+		"message": message,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func Forbidden(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+
+	response := map[string]interface{}{
+		"status":  403,
+		"success": false,
+		"message": message,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,12 @@
+package utils
+
+import "fmt"
+
+func InviteEmail(code string, expiresAt string) string {
+	return EmailLayout("You are Invited", fmt.Sprintf(`
+					<p>Hello,</p>
+					<p>You have been invited to create an account on Blog App. Use this invite code when you sign up:</p>
+					<h2 class="highlight">%s</h2>
+					<p>The code is valid until %s. If you were not expecting this invite, please disregard this email.</p>
+	`, code, expiresAt))
+}
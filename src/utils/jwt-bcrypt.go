@@ -1,77 +0,0 @@
-package utils
-
-import (
-	"errors"
-	"os"
-	"time"
-
-	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
-)
-
-func GenerateJWT(id, email, profileId string, verified bool) (string, error) {
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		return "", errors.New("JWT secret not found in environment variables")
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"id":        id,
-		"email":     email,
-		"verified":  verified,
-		"profileId": profileId,
-		"exp":       time.Now().Add(72 * time.Hour).Unix(), // Token expiration (3 days)
-	})
-
-	tokenString, err := token.SignedString([]byte(jwtSecret))
-	if err != nil {
-		return "", err
-	}
-
-	return tokenString, nil
-}
-
-func ValidateJWT(tokenString string) (string, string, string, bool, error) {
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		return "", "", "", false, errors.New("JWT secret not found in environment variables")
-	}
-
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(jwtSecret), nil
-	})
-
-	if err != nil {
-		return "", "", "", false, err
-	}
-
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		if exp, ok := claims["exp"].(float64); ok {
-			if time.Now().Unix() > int64(exp) {
-				return "", "", "", false, errors.New("token has expired")
-			}
-		}
-
-		id, _ := claims["id"].(string)
-		email, _ := claims["email"].(string)
-		profileId, _ := claims["profileId"].(string)
-		verified, _ := claims["verified"].(bool)
-
-		return id, email, profileId, verified, nil
-	}
-
-	return "", "", "", false, errors.New("invalid token")
-}
-
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-	return string(bytes), err
-}
-
-func CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
-}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Lifetime of the access token handed out on login and refresh
+const AccessTokenTTL = 15 * time.Minute
+
+// Lifetime of a refresh token before the user has to login again
+const RefreshTokenTTL = 7 * 24 * time.Hour
+
+// Claims of an access token
+type AccessClaims struct {
+	Id        string `json:"id"`
+	Email     string `json:"email"`
+	Verified  bool   `json:"verified"`
+	ProfileId string `json:"profileId"`
+	SessionId string `json:"sid"`
+	Role      string `json:"role"`
+	Purpose   string `json:"purpose,omitempty"` // Only set on challenge tokens
+	jwt.RegisteredClaims
+}
+
+func GenerateJWT(id, email, profileId, sessionId, role string, verified bool) (string, error) {
+	now := time.Now()
+
+	return signJWT(&AccessClaims{
+		Id:        id,
+		Email:     email,
+		Verified:  verified,
+		ProfileId: profileId,
+		SessionId: sessionId,
+		Role:      role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)),
+		},
+	})
+}
+
+func ValidateJWT(tokenString string) (*AccessClaims, error) {
+	var claims AccessClaims
+
+	if err := parseJWT(tokenString, &claims); err != nil {
+		return nil, err
+	}
+
+	// Challenge tokens are not access tokens
+	if claims.Purpose != "" {
+		return nil, errors.New("invalid token")
+	}
+
+	return &claims, nil
+}
+
+// Lifetime of the challenge token handed out when a second factor is needed
+const ChallengeTokenTTL = 5 * time.Minute
+
+// Wrong second factors a challenge token survives before it is burned
+const ChallengeMaxAttempts = 5
+
+// Claims of a challenge token
+type ChallengeClaims struct {
+	Id      string `json:"id"`
+	Purpose string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+// Short-lived token proving the password was checked, exchanged for a session
+// once the second factor is verified. challengeId becomes its jti.
+func GenerateChallengeJWT(id, challengeId, purpose string) (string, error) {
+	now := time.Now()
+
+	return signJWT(&ChallengeClaims{
+		Id:      id,
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        challengeId,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ChallengeTokenTTL)),
+		},
+	})
+}
+
+// Check a challenge token, returning the id it was issued for and its jti
+func ValidateChallengeJWT(tokenString, purpose string) (string, string, error) {
+	var claims ChallengeClaims
+
+	if err := parseJWT(tokenString, &claims); err != nil {
+		return "", "", err
+	}
+
+	if claims.Purpose != purpose || claims.ID == "" {
+		return "", "", errors.New("invalid challenge token")
+	}
+
+	return claims.Id, claims.ID, nil
+}
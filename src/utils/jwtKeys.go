@@ -0,0 +1,233 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Algorithms tokens can be signed with, set with JWT_ALGORITHM
+const (
+	JWTAlgorithmHS256 = "HS256"
+	JWTAlgorithmRS256 = "RS256"
+	JWTAlgorithmEdDSA = "EdDSA"
+)
+
+// A key pair used to sign or verify tokens, identified by the kid header
+type JWTKey struct {
+	ID         string
+	Algorithm  string
+	PrivateKey crypto.Signer
+}
+
+func (k *JWTKey) signingMethod() jwt.SigningMethod {
+	if k.Algorithm == JWTAlgorithmEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// Keys used by GenerateJWT and ValidateJWT. Without a signing key tokens fall back to HS256 with the secret.
+var jwtKeys struct {
+	sync.RWMutex
+	secret  []byte
+	signing *JWTKey
+	keys    map[string]*JWTKey
+}
+
+// Use HS256 with a shared secret
+func SetJWTSecret(secret string) error {
+	if secret == "" {
+		return errors.New("JWT secret not found in environment variables")
+	}
+
+	jwtKeys.Lock()
+	defer jwtKeys.Unlock()
+
+	jwtKeys.secret = []byte(secret)
+
+	return nil
+}
+
+// Replace the key set. The signing key signs new tokens, every key in keys still verifies the tokens it signed.
+func SetJWTKeys(signing *JWTKey, keys []*JWTKey) {
+	keySet := make(map[string]*JWTKey, len(keys)+1)
+	for _, key := range keys {
+		keySet[key.ID] = key
+	}
+	keySet[signing.ID] = signing
+
+	jwtKeys.Lock()
+	defer jwtKeys.Unlock()
+
+	jwtKeys.signing = signing
+	jwtKeys.keys = keySet
+}
+
+// Generate a new key pair for the algorithm with a random kid
+func GenerateJWTKey(algorithm string) (*JWTKey, error) {
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	key := &JWTKey{ID: base64.RawURLEncoding.EncodeToString(id), Algorithm: algorithm}
+
+	switch algorithm {
+	case JWTAlgorithmRS256:
+		privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		key.PrivateKey = privateKey
+	case JWTAlgorithmEdDSA:
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		key.PrivateKey = privateKey
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", algorithm)
+	}
+
+	return key, nil
+}
+
+// Encode the private key as PKCS8 PEM, to be encrypted and stored
+func (k *JWTKey) MarshalPrivateKey() (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// Read back a key stored with MarshalPrivateKey
+func ParseJWTKey(id, algorithm, privateKeyPEM string) (*JWTKey, error) {
+	block, _ := pem.Decode([]byte(privateKeyPEM))
+	if block == nil {
+		return nil, errors.New("invalid private key PEM")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &JWTKey{ID: id, Algorithm: algorithm}
+
+	switch privateKey := parsed.(type) {
+	case *rsa.PrivateKey:
+		if algorithm != JWTAlgorithmRS256 {
+			return nil, errors.New("RSA key stored for " + algorithm)
+		}
+		key.PrivateKey = privateKey
+	case ed25519.PrivateKey:
+		if algorithm != JWTAlgorithmEdDSA {
+			return nil, errors.New("Ed25519 key stored for " + algorithm)
+		}
+		key.PrivateKey = privateKey
+	default:
+		return nil, errors.New("unsupported private key type")
+	}
+
+	return key, nil
+}
+
+// Public keys of the current key set as a JSON Web Key Set
+func JWKS() map[string]interface{} {
+	jwtKeys.RLock()
+	defer jwtKeys.RUnlock()
+
+	keys := []map[string]interface{}{}
+
+	for _, key := range jwtKeys.keys {
+		jwk := map[string]interface{}{
+			"kid": key.ID,
+			"alg": key.Algorithm,
+			"use": "sig",
+		}
+
+		switch publicKey := key.PrivateKey.Public().(type) {
+		case *rsa.PublicKey:
+			jwk["kty"] = "RSA"
+			jwk["n"] = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+			jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk["kty"] = "OKP"
+			jwk["crv"] = "Ed25519"
+			jwk["x"] = base64.RawURLEncoding.EncodeToString(publicKey)
+		default:
+			continue
+		}
+
+		keys = append(keys, jwk)
+	}
+
+	return map[string]interface{}{"keys": keys}
+}
+
+// Sign claims with the current signing key
+func signJWT(claims jwt.Claims) (string, error) {
+	jwtKeys.RLock()
+	defer jwtKeys.RUnlock()
+
+	if jwtKeys.signing != nil {
+		token := jwt.NewWithClaims(jwtKeys.signing.signingMethod(), claims)
+		token.Header["kid"] = jwtKeys.signing.ID
+
+		return token.SignedString(jwtKeys.signing.PrivateKey)
+	}
+
+	if jwtKeys.secret == nil {
+		return "", errors.New("JWT signing keys are not initialised")
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtKeys.secret)
+}
+
+// Parse a token signed by signJWT into claims
+func parseJWT(tokenString string, claims jwt.Claims) error {
+	jwtKeys.RLock()
+	defer jwtKeys.RUnlock()
+
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		if jwtKeys.signing == nil {
+			if jwtKeys.secret == nil {
+				return nil, errors.New("JWT signing keys are not initialised")
+			}
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, errors.New("unexpected signing method")
+			}
+			return jwtKeys.secret, nil
+		}
+
+		kid, _ := token.Header["kid"].(string)
+
+		key, ok := jwtKeys.keys[kid]
+		if !ok {
+			return nil, errors.New("unknown signing key")
+		}
+
+		if token.Method.Alg() != key.signingMethod().Alg() {
+			return nil, errors.New("unexpected signing method")
+		}
+
+		return key.PrivateKey.Public(), nil
+	}
+
+	_, err := jwt.ParseWithClaims(tokenString, claims, keyFunc, jwt.WithExpirationRequired())
+
+	return err
+}
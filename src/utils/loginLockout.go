@@ -0,0 +1,41 @@
+package utils
+
+import "time"
+
+// Failed logins allowed before any delay kicks in
+const LoginFreeAttempts = 3
+
+// Longest delay between two failed logins before the lockout
+const LoginMaxBackoff = 30 * time.Second
+
+// Failures older than this are forgotten
+const LoginFailureWindow = time.Hour
+
+// Failed logins on one account before it is locked
+func LoginLockoutThreshold() int {
+	return GetEnvInt("LOGIN_LOCKOUT_THRESHOLD", 10)
+}
+
+// Failed logins from one IP, across accounts, before it is locked out
+func LoginIPLockoutThreshold() int {
+	return GetEnvInt("LOGIN_IP_LOCKOUT_THRESHOLD", 50)
+}
+
+// How long a lockout lasts
+func LoginLockoutDuration() time.Duration {
+	return time.Duration(GetEnvInt("LOGIN_LOCKOUT_MINUTES", 15)) * time.Minute
+}
+
+// Delay required after the given number of failures, doubling with each one
+func LoginBackoff(failures int) time.Duration {
+	if failures < LoginFreeAttempts {
+		return 0
+	}
+
+	backoff := time.Second << (failures - LoginFreeAttempts)
+	if backoff > LoginMaxBackoff || backoff <= 0 {
+		return LoginMaxBackoff
+	}
+
+	return backoff
+}
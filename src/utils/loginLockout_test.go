@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{LoginFreeAttempts - 1, 0},
+		{LoginFreeAttempts, time.Second},
+		{LoginFreeAttempts + 1, 2 * time.Second},
+		{LoginFreeAttempts + 4, 16 * time.Second},
+		{LoginFreeAttempts + 5, LoginMaxBackoff},
+		{LoginFreeAttempts + 100, LoginMaxBackoff},
+	}
+
+	for _, test := range tests {
+		if got := LoginBackoff(test.failures); got != test.want {
+			t.Errorf("LoginBackoff(%d) = %v, want %v", test.failures, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package utils
+
+import "fmt"
+
+func MagicLinkEmail(link string) string {
+	return EmailLayout("Login Link", fmt.Sprintf(`
+					<p>Dear User,</p>
+					<p>Click the link below to login to your Blog App account. No password needed:</p>
+					<p><a href="%s">Login to Blog App</a></p>
+					<p>This link is valid for %d minutes and can only be used once. If you did not request it, please
+						disregard this email.</p>
+	`, link, int(MagicLinkTTL.Minutes())))
+}
@@ -0,0 +1,98 @@
+package utils
+
+// Two-factor form and helpers shared by the login pages this server hosts.
+// loginPageScript expects the form below and a #status element.
+const twoFactorFormHTML = `
+					<form id="twoFactor" hidden>
+						<p><input id="code" inputmode="numeric" autocomplete="one-time-code" maxlength="6" placeholder="Two-factor code"></p>
+						<p><button type="submit">Verify</button></p>
+					</form>
+					<p id="status"></p>`
+
+const loginPageScript = `
+						const params = new URLSearchParams(location.hash.slice(1));
+						const status = document.getElementById("status");
+						const twoFactor = document.getElementById("twoFactor");
+						let challengeToken = "";
+
+						async function post(path, body) {
+							const response = await fetch(path, {
+								method: "POST",
+								headers: { "Content-Type": "application/json" },
+								credentials: "same-origin",
+								body: JSON.stringify(body),
+							});
+							const result = await response.json().catch(() => ({}));
+							return { ok: response.ok, result };
+						}
+
+						function done({ ok, result }) {
+							if (ok) {
+								twoFactor.hidden = true;
+								status.textContent = "You are logged in. You can close this page.";
+							} else {
+								status.textContent = result.message || "Login failed. Please try again.";
+							}
+						}
+
+						function askTwoFactorCode(token) {
+							challengeToken = token;
+							twoFactor.hidden = false;
+							status.textContent = "Enter the code from your authenticator app.";
+						}
+
+						twoFactor.addEventListener("submit", async (event) => {
+							event.preventDefault();
+							const code = document.getElementById("code").value;
+							done(await post("/user/2fa/verify", { challengeToken, code }));
+						});`
+
+// Page the emailed login link opens. Nothing is spent by opening it, so mail scanners and
+// link previews cannot burn the link. The token stays in the fragment, which browsers
+// never send to servers, and is only posted when the User clicks the button.
+func MagicLinkPage() string {
+	return EmailLayout("Login Link", `
+					<p>Click the button below to finish logging in to your Blog App account.</p>
+					<p><button id="login" type="button">Login to Blog App</button></p>`+twoFactorFormHTML+`
+					<script>`+loginPageScript+`
+						const token = params.get("token");
+						const button = document.getElementById("login");
+
+						if (!token) {
+							button.disabled = true;
+							status.textContent = "This login link is incomplete. Please request a new one.";
+						}
+
+						button.addEventListener("click", async () => {
+							button.disabled = true;
+
+							const { ok, result } = await post("/auth/magic-link/consume", { token });
+							if (result.twoFactorRequired) {
+								askTwoFactorCode(result.challengeToken);
+								return;
+							}
+							done({ ok, result });
+						});
+					</script>
+	`)
+}
+
+// Page OAuthCallback redirects to. The outcome is in the fragment: status=success once the
+// session cookies are set, twoFactorChallenge when a code is still needed, or error.
+func OAuthResultPage() string {
+	return EmailLayout("Login", `
+					<p>Finishing your login to Blog App.</p>`+twoFactorFormHTML+`
+					<script>`+loginPageScript+`
+						if (params.get("twoFactorChallenge")) {
+							askTwoFactorCode(params.get("twoFactorChallenge"));
+						} else if (params.get("status") === "success") {
+							done({ ok: true, result: {} });
+						} else {
+							status.textContent = params.get("error") || "Login failed. Please try again.";
+						}
+
+						// Keep the challenge out of the history once it was read
+						history.replaceState(null, "", location.pathname);
+					</script>
+	`)
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+)
+
+// How long an OTP can be used after it was sent
+func OtpTTL() time.Duration {
+	return time.Duration(GetEnvInt("OTP_TTL_MINUTES", 5)) * time.Minute
+}
+
+// Number of wrong guesses before an OTP is locked
+func OtpMaxAttempts() int {
+	return GetEnvInt("OTP_MAX_ATTEMPTS", 5)
+}
+
+// Minimum time between two OTPs sent to the same email for the same purpose
+func OtpResendCooldown() time.Duration {
+	return time.Duration(GetEnvInt("OTP_RESEND_COOLDOWN_SECONDS", 60)) * time.Second
+}
+
+// Generate a uniformly distributed 6 digit code
+func GenerateOtp() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// Keyed hash of an OTP, bound to the email and purpose it was issued for
+func HashOtp(email, purpose, otp string) (string, error) {
+	otpSecret := os.Getenv("OTP_SECRET")
+	if otpSecret == "" {
+		return "", errors.New("OTP secret not found in environment variables")
+	}
+
+	mac := hmac.New(sha256.New, []byte(otpSecret))
+	mac.Write([]byte(purpose + ":" + email + ":" + otp))
+
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
@@ -0,0 +1,11 @@
+package utils
+
+func PasswordChangedEmail() string {
+	return EmailLayout("Password Changed", `
+					<p>Dear User,</p>
+					<p>The password of your Blog App account was just changed. All of your other sessions have been
+						logged out.</p>
+					<p>If you made this change, you can disregard this email. If you did not, please reset your password
+						right away and reach out to us.</p>
+	`)
+}
@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hashes passwords with one algorithm and set of parameters
+type PasswordHasher interface {
+	// Hash a password, the result carries the algorithm and parameters
+	Hash(password string) (string, error)
+	// Whether the hash was made by this algorithm
+	Recognizes(hash string) bool
+	// Check a password against a hash this hasher recognizes
+	Verify(password, hash string) bool
+	// Whether a recognized hash was made with other parameters than the current ones
+	Outdated(hash string) bool
+}
+
+// Argon2id, encoded in the PHC string format
+type Argon2idHasher struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+const argon2idPrefix = "$argon2id$"
+
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version, h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h *Argon2idHasher) Recognizes(hash string) bool {
+	return strings.HasPrefix(hash, argon2idPrefix)
+}
+
+// Read the parameters, salt and key out of an encoded hash
+func decodeArgon2id(hash string) (*Argon2idHasher, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return nil, nil, nil, errors.New("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, errors.New("unsupported argon2 version")
+	}
+
+	var params Argon2idHasher
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return nil, nil, nil, err
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return &params, salt, key, nil
+}
+
+func (h *Argon2idHasher) Verify(password, hash string) bool {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return false
+	}
+
+	candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+
+	return subtle.ConstantTimeCompare(key, candidate) == 1
+}
+
+func (h *Argon2idHasher) Outdated(hash string) bool {
+	params, _, _, err := decodeArgon2id(hash)
+	if err != nil {
+		return true
+	}
+
+	return params.Memory != h.Memory || params.Iterations != h.Iterations || params.Parallelism != h.Parallelism ||
+		params.SaltLength != h.SaltLength || params.KeyLength != h.KeyLength
+}
+
+// bcrypt, kept to verify older hashes or when argon2id is not wanted
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	return string(bytes), err
+}
+
+func (h *BcryptHasher) Recognizes(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func (h *BcryptHasher) Verify(password, hash string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+func (h *BcryptHasher) Outdated(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.Cost
+}
+
+// Hashers configured from the environment, the one picked by PASSWORD_HASHER comes first
+var passwordHashers = sync.OnceValue(newPasswordHashers)
+
+func newPasswordHashers() []PasswordHasher {
+	argon2id := &Argon2idHasher{
+		Memory:      uint32(GetEnvInt("ARGON2_MEMORY_KIB", 19456)),
+		Iterations:  uint32(GetEnvInt("ARGON2_ITERATIONS", 2)),
+		Parallelism: uint8(GetEnvInt("ARGON2_PARALLELISM", 1)),
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+
+	bcryptHasher := &BcryptHasher{Cost: GetEnvInt("BCRYPT_COST", 12)}
+
+	if os.Getenv("PASSWORD_HASHER") == "bcrypt" {
+		return []PasswordHasher{bcryptHasher, argon2id}
+	}
+
+	return []PasswordHasher{argon2id, bcryptHasher}
+}
+
+// Hasher used for new passwords
+func ActivePasswordHasher() PasswordHasher {
+	return passwordHashers()[0]
+}
+
+func HashPassword(password string) (string, error) {
+	return ActivePasswordHasher().Hash(password)
+}
+
+func CheckPasswordHash(password, hash string) bool {
+	for _, hasher := range passwordHashers() {
+		if hasher.Recognizes(hash) {
+			return hasher.Verify(password, hash)
+		}
+	}
+
+	return false
+}
+
+// Whether a stored hash should be replaced by one from the active hasher
+func PasswordNeedsRehash(hash string) bool {
+	active := ActivePasswordHasher()
+
+	return !active.Recognizes(hash) || active.Outdated(hash)
+}
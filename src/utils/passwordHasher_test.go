@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"sync"
+	"testing"
+)
+
+// Use cheap parameters, the tests only care about which hashes count as outdated
+func setTestPasswordHashers(t *testing.T, hasher string) {
+	t.Setenv("PASSWORD_HASHER", hasher)
+	t.Setenv("ARGON2_MEMORY_KIB", "64")
+	t.Setenv("ARGON2_ITERATIONS", "1")
+	t.Setenv("BCRYPT_COST", "4")
+
+	passwordHashers = sync.OnceValue(newPasswordHashers)
+	t.Cleanup(func() { passwordHashers = sync.OnceValue(newPasswordHashers) })
+}
+
+func mustHash(t *testing.T, hasher PasswordHasher, password string) string {
+	t.Helper()
+
+	hash, err := hasher.Hash(password)
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+
+	return hash
+}
+
+func TestPasswordNeedsRehash(t *testing.T) {
+	setTestPasswordHashers(t, "argon2id")
+
+	current := mustHash(t, ActivePasswordHasher(), "password")
+	otherParams := mustHash(t, &Argon2idHasher{Memory: 64, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}, "password")
+	bcryptHash := mustHash(t, &BcryptHasher{Cost: 4}, "password")
+
+	tests := []struct {
+		name string
+		hash string
+		want bool
+	}{
+		{"current argon2id", current, false},
+		{"argon2id with other parameters", otherParams, true},
+		{"bcrypt while argon2id is active", bcryptHash, true},
+		{"unknown format", "plain-text", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := PasswordNeedsRehash(test.hash); got != test.want {
+				t.Errorf("PasswordNeedsRehash() = %v, want %v", got, test.want)
+			}
+		})
+	}
+
+	// Outdated hashes still verify, so the login that rehashes them can succeed
+	for _, hash := range []string{current, otherParams, bcryptHash} {
+		if !CheckPasswordHash("password", hash) {
+			t.Errorf("CheckPasswordHash() = false for %s", hash)
+		}
+	}
+}
+
+func TestPasswordNeedsRehashBcrypt(t *testing.T) {
+	setTestPasswordHashers(t, "bcrypt")
+
+	if PasswordNeedsRehash(mustHash(t, ActivePasswordHasher(), "password")) {
+		t.Error("PasswordNeedsRehash() = true for a current bcrypt hash")
+	}
+	if !PasswordNeedsRehash(mustHash(t, &BcryptHasher{Cost: 5}, "password")) {
+		t.Error("PasswordNeedsRehash() = false for a bcrypt hash of another cost")
+	}
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"errors"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	minPasswordLength       = 8
+	maxPasswordLength       = 256 // Keeps hashing cheap enough for argon2id
+	maxBcryptPasswordLength = 72  // bcrypt ignores everything after 72 bytes
+)
+
+// Check that a new password is strong enough to be accepted
+func ValidatePasswordStrength(password string) error {
+	length := utf8.RuneCountInString(password)
+
+	if length < minPasswordLength {
+		return errors.New("Password must be at least 8 characters long")
+	}
+
+	if length > maxPasswordLength {
+		return errors.New("Password must be at most 256 characters long")
+	}
+
+	if _, isBcrypt := ActivePasswordHasher().(*BcryptHasher); isBcrypt && len(password) > maxBcryptPasswordLength {
+		return errors.New("Password must be at most 72 bytes long, fewer characters when they are not plain ASCII")
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSpecial bool
+
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			hasUpper = true
+		case unicode.IsLower(c):
+			hasLower = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c):
+			hasSpecial = true
+		}
+	}
+
+	if !hasUpper || !hasLower || !hasDigit || !hasSpecial {
+		return errors.New("Password must contain an uppercase letter, a lowercase letter, a digit and a special character")
+	}
+
+	return nil
+}
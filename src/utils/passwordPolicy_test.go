@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidatePasswordStrength(t *testing.T) {
+	tests := []struct {
+		name     string
+		hasher   string
+		password string
+		valid    bool
+	}{
+		{"strong", "argon2id", "Str0ng;Pass()=", true},
+		{"too short", "argon2id", "Aa1;", false},
+		{"no special character", "argon2id", "Str0ngPass", false},
+		{"no digit", "argon2id", "Strong;Pass", false},
+		{"long with argon2id", "argon2id", "Aa1;" + strings.Repeat("x", 100), true},
+		{"long with bcrypt", "bcrypt", "Aa1;" + strings.Repeat("x", 100), false},
+		{"too long", "argon2id", "Aa1;" + strings.Repeat("x", 300), false},
+		{"long enough in bytes but short in characters", "argon2id", "Ää1;ééé", false},
+		{"multibyte characters counted once", "argon2id", "Ää1;éééé", true},
+		{"multibyte over the bcrypt byte limit", "bcrypt", "Aa1;" + strings.Repeat("é", 40), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setTestPasswordHashers(t, test.hasher)
+
+			err := ValidatePasswordStrength(test.password)
+			if (err == nil) != test.valid {
+				t.Errorf("ValidatePasswordStrength() error = %v, want valid %v", err, test.valid)
+			}
+		})
+	}
+}
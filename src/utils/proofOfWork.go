@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/bits"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How long a proof-of-work challenge can be solved and used
+const PowChallengeTTL = 5 * time.Minute
+
+// Window of recently issued and redeemed challenges the difficulty is based on
+const PowActivityWindow = 10 * time.Minute
+
+// Longest solution accepted, to keep hashing cheap for the server
+const PowMaxSolutionLength = 64
+
+var (
+	ErrPowChallengeInvalid = errors.New("invalid or expired challenge")
+	ErrPowSolutionInvalid  = errors.New("wrong challenge solution")
+)
+
+// Leading zero bits required of a solution when signups are quiet
+func PowBaseDifficulty() int {
+	return GetEnvInt("POW_BASE_DIFFICULTY", 18)
+}
+
+// Upper bound of the difficulty however busy signups get
+func PowMaxDifficulty() int {
+	return GetEnvInt("POW_MAX_DIFFICULTY", 24)
+}
+
+// Challenges issued or redeemed within PowActivityWindow before the difficulty starts to grow
+func PowChallengeThreshold() int {
+	return GetEnvInt("POW_CHALLENGE_THRESHOLD", 20)
+}
+
+// Difficulty for the given number of recent challenges. Every doubling over the threshold
+// adds a bit, which doubles the work a client has to do.
+func PowDifficulty(recentChallenges int) int {
+	difficulty := PowBaseDifficulty()
+
+	for load := recentChallenges / PowChallengeThreshold(); load > 0 && difficulty < PowMaxDifficulty(); load /= 2 {
+		difficulty++
+	}
+
+	return difficulty
+}
+
+// A puzzle handed out by /auth/challenge
+type PowChallenge struct {
+	Nonce      string
+	Difficulty int
+	ExpiresAt  int64
+}
+
+func powSignature(nonce string, difficulty int, expiresAt int64) (string, error) {
+	encryptionKey := os.Getenv("ENCRYPTION_KEY")
+	if encryptionKey == "" {
+		return "", errors.New("Encryption key not found in environment variables")
+	}
+
+	mac := hmac.New(sha256.New, []byte("pow-challenge:"+encryptionKey))
+	mac.Write([]byte(fmt.Sprintf("%s|%d|%d", nonce, difficulty, expiresAt)))
+
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// Create a signed challenge of the given difficulty. The token carries everything
+// needed to check it later, so nothing is stored until it is used.
+func NewPowChallenge(difficulty int) (string, *PowChallenge, error) {
+	nonceBytes := make([]byte, 16)
+	if _, err := rand.Read(nonceBytes); err != nil {
+		return "", nil, err
+	}
+
+	challenge := &PowChallenge{
+		Nonce:      hex.EncodeToString(nonceBytes),
+		Difficulty: difficulty,
+		ExpiresAt:  time.Now().Add(PowChallengeTTL).Unix(),
+	}
+
+	signature, err := powSignature(challenge.Nonce, challenge.Difficulty, challenge.ExpiresAt)
+	if err != nil {
+		return "", nil, err
+	}
+
+	token := fmt.Sprintf("%s.%d.%d.%s", challenge.Nonce, challenge.Difficulty, challenge.ExpiresAt, signature)
+
+	return token, challenge, nil
+}
+
+// Check the signature and expiry of a token made by NewPowChallenge, then the solution.
+// A solution is any string for which SHA-256(token + ":" + solution) starts with
+// at least Difficulty zero bits.
+func VerifyPowSolution(token, solution string) (*PowChallenge, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 4 {
+		return nil, ErrPowChallengeInvalid
+	}
+
+	difficulty, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, ErrPowChallengeInvalid
+	}
+
+	expiresAt, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return nil, ErrPowChallengeInvalid
+	}
+
+	signature, err := powSignature(parts[0], difficulty, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(parts[3])) {
+		return nil, ErrPowChallengeInvalid
+	}
+
+	if solution == "" || len(solution) > PowMaxSolutionLength || powLeadingZeroBits(token, solution) < difficulty {
+		return nil, ErrPowSolutionInvalid
+	}
+
+	return &PowChallenge{Nonce: parts[0], Difficulty: difficulty, ExpiresAt: expiresAt}, nil
+}
+
+func powLeadingZeroBits(token, solution string) int {
+	sum := sha256.Sum256([]byte(token + ":" + solution))
+
+	zeros := 0
+	for _, b := range sum {
+		if b != 0 {
+			return zeros + bits.LeadingZeros8(b)
+		}
+		zeros += 8
+	}
+
+	return zeros
+}
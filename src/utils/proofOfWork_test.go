@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Brute force a solution, cheap at the low difficulties used here
+func solvePowChallenge(t *testing.T, token string, difficulty int) string {
+	t.Helper()
+
+	for i := 0; i < 1<<20; i++ {
+		solution := strconv.Itoa(i)
+		if powLeadingZeroBits(token, solution) >= difficulty {
+			return solution
+		}
+	}
+
+	t.Fatal("no solution found")
+	return ""
+}
+
+func TestVerifyPowSolution(t *testing.T) {
+	t.Setenv("ENCRYPTION_KEY", "test-key")
+
+	token, challenge, err := NewPowChallenge(8)
+	if err != nil {
+		t.Fatalf("NewPowChallenge() error = %v", err)
+	}
+
+	solution := solvePowChallenge(t, token, 8)
+
+	verified, err := VerifyPowSolution(token, solution)
+	if err != nil {
+		t.Fatalf("VerifyPowSolution() error = %v", err)
+	}
+	if *verified != *challenge {
+		t.Errorf("VerifyPowSolution() = %+v, want %+v", verified, challenge)
+	}
+
+	var wrong string
+	for i := 0; ; i++ {
+		if wrong = "x" + strconv.Itoa(i); powLeadingZeroBits(token, wrong) < 8 {
+			break
+		}
+	}
+
+	// An easier difficulty in the token breaks its signature
+	parts := strings.Split(token, ".")
+	parts[1] = "1"
+	easier := strings.Join(parts, ".")
+
+	tests := []struct {
+		name     string
+		token    string
+		solution string
+		want     error
+	}{
+		{"wrong solution", token, wrong, ErrPowSolutionInvalid},
+		{"empty solution", token, "", ErrPowSolutionInvalid},
+		{"too long solution", token, strings.Repeat("a", PowMaxSolutionLength+1), ErrPowSolutionInvalid},
+		{"changed difficulty", easier, solution, ErrPowChallengeInvalid},
+		{"malformed token", "not-a-token", solution, ErrPowChallengeInvalid},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := VerifyPowSolution(test.token, test.solution); !errors.Is(err, test.want) {
+				t.Errorf("VerifyPowSolution() error = %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestVerifyPowSolutionExpired(t *testing.T) {
+	t.Setenv("ENCRYPTION_KEY", "test-key")
+
+	signature, err := powSignature("00", 0, 1)
+	if err != nil {
+		t.Fatalf("powSignature() error = %v", err)
+	}
+
+	if _, err := VerifyPowSolution("00.0.1."+signature, "a"); !errors.Is(err, ErrPowChallengeInvalid) {
+		t.Errorf("VerifyPowSolution() error = %v, want %v", err, ErrPowChallengeInvalid)
+	}
+}
+
+func TestPowDifficulty(t *testing.T) {
+	t.Setenv("POW_BASE_DIFFICULTY", "18")
+	t.Setenv("POW_MAX_DIFFICULTY", "21")
+	t.Setenv("POW_CHALLENGE_THRESHOLD", "20")
+
+	tests := []struct {
+		recentChallenges int
+		want             int
+	}{
+		{0, 18},
+		{19, 18},
+		{20, 19},
+		{40, 20},
+		{80, 21},
+		{10000, 21},
+	}
+
+	for _, test := range tests {
+		if got := PowDifficulty(test.recentChallenges); got != test.want {
+			t.Errorf("PowDifficulty(%d) = %d, want %d", test.recentChallenges, got, test.want)
+		}
+	}
+}
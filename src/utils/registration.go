@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"os"
+	"strings"
+	"time"
+)
+
+// Who can create an account
+const (
+	RegistrationOpen   = "open"   // Anyone
+	RegistrationInvite = "invite" // Only with an invite code from an Admin
+	RegistrationClosed = "closed" // Nobody
+)
+
+// Validity of an invite when the Admin does not pick one
+const DefaultInviteTTL = 7 * 24 * time.Hour
+
+// Registration mode from REGISTRATION_MODE, open when unset or unknown
+func RegistrationMode() string {
+	switch mode := strings.ToLower(strings.TrimSpace(os.Getenv("REGISTRATION_MODE"))); mode {
+	case RegistrationInvite, RegistrationClosed:
+		return mode
+	default:
+		return RegistrationOpen
+	}
+}
@@ -0,0 +1,14 @@
+package utils
+
+import "fmt"
+
+func ResetPasswordEmail(otp string) string {
+	return EmailLayout("Password Reset Email", fmt.Sprintf(`
+					<p>Dear User,</p>
+					<p>We received a request to reset the password of your Blog App account. Please use the following code
+						to choose a new password:</p>
+					<h2 class="highlight">%s</h2>
+					<p>This code is valid for %d minutes. If you did not request a password reset, please disregard this email.
+					Your password will stay the same.</p>
+	`, otp, int(OtpTTL().Minutes())))
+}
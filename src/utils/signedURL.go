@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+func urlSignature(path string, expiresAt int64) (string, error) {
+	encryptionKey := os.Getenv("ENCRYPTION_KEY")
+	if encryptionKey == "" {
+		return "", errors.New("Encryption key not found in environment variables")
+	}
+
+	mac := hmac.New(sha256.New, []byte("signed-url:"+encryptionKey))
+	mac.Write([]byte(fmt.Sprintf("%s|%d", path, expiresAt)))
+
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// Sign a path so it can be opened without logging in until it expires
+func SignURL(path string, ttl time.Duration) (string, error) {
+	expiresAt := time.Now().Add(ttl).Unix()
+
+	signature, err := urlSignature(path, expiresAt)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expiresAt, 10))
+	query.Set("signature", signature)
+
+	return path + "?" + query.Encode(), nil
+}
+
+// Check the signature and expiry of a URL made by SignURL
+func VerifySignedURL(path string, query url.Values) bool {
+	expiresAt, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return false
+	}
+
+	signature, err := urlSignature(path, expiresAt)
+	if err != nil {
+		return false
+	}
+
+	return hmac.Equal([]byte(signature), []byte(query.Get("signature")))
+}
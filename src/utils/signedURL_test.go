@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignedURL(t *testing.T) {
+	t.Setenv("ENCRYPTION_KEY", "test-key")
+
+	signed, err := SignURL("/user/export/download/1", time.Minute)
+	if err != nil {
+		t.Fatalf("SignURL() error = %v", err)
+	}
+
+	path, rawQuery, _ := strings.Cut(signed, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		t.Fatalf("ParseQuery() error = %v", err)
+	}
+
+	if !VerifySignedURL(path, query) {
+		t.Error("VerifySignedURL() = false for the signed URL")
+	}
+
+	if VerifySignedURL("/user/export/download/2", query) {
+		t.Error("VerifySignedURL() = true for another path")
+	}
+
+	tampered := url.Values{"expires": {query.Get("expires") + "0"}, "signature": {query.Get("signature")}}
+	if VerifySignedURL(path, tampered) {
+		t.Error("VerifySignedURL() = true with a changed expiry")
+	}
+
+	t.Setenv("ENCRYPTION_KEY", "other-key")
+	if VerifySignedURL(path, query) {
+		t.Error("VerifySignedURL() = true with another key")
+	}
+}
+
+func TestSignedURLExpired(t *testing.T) {
+	t.Setenv("ENCRYPTION_KEY", "test-key")
+
+	signed, err := SignURL("/user/export/download/1", -time.Minute)
+	if err != nil {
+		t.Fatalf("SignURL() error = %v", err)
+	}
+
+	path, rawQuery, _ := strings.Cut(signed, "?")
+	query, _ := url.ParseQuery(rawQuery)
+
+	if VerifySignedURL(path, query) {
+		t.Error("VerifySignedURL() = true for an expired URL")
+	}
+}
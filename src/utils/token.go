@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"time"
+)
+
+// Lifetime of an emailed login link
+const MagicLinkTTL = 15 * time.Minute
+
+// Page emailed login links open, MAGIC_LINK_PAGE_URL or the page this server hosts.
+// A frontend hosting its own page has to POST the token from the fragment to /auth/magic-link/consume.
+func MagicLinkPageURL() string {
+	pageURL := os.Getenv("MAGIC_LINK_PAGE_URL")
+	if pageURL == "" {
+		pageURL = "http://localhost:3000/magic-link"
+	}
+
+	return pageURL
+}
+
+// Page OAuthCallback redirects to with the outcome in the fragment, OAUTH_RESULT_PAGE_URL or the page
+// this server hosts. A frontend hosting its own page reads status, twoFactorChallenge or error from it.
+func OAuthResultPageURL() string {
+	pageURL := os.Getenv("OAUTH_RESULT_PAGE_URL")
+	if pageURL == "" {
+		pageURL = "http://localhost:3000/oauth/result"
+	}
+
+	return pageURL
+}
+
+// Generate an opaque random token, safe to put in cookies and URLs
+func GenerateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Hash a token before storing or looking it up in the database
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// PKCE S256 code challenge for a code verifier
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// Base32 of the RFC 6238 SHA-1 test secret "12345678901234567890"
+const rfcTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidateTOTP(t *testing.T) {
+	// Last six digits of the RFC 6238 test vectors
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+	}
+
+	for _, test := range tests {
+		step, ok := ValidateTOTP(rfcTOTPSecret, test.code, time.Unix(test.unix, 0))
+		if !ok || step != test.unix/totpPeriod {
+			t.Errorf("ValidateTOTP(%s at %d) = (%d, %v), want (%d, true)", test.code, test.unix, step, ok, test.unix/totpPeriod)
+		}
+	}
+}
+
+func TestValidateTOTPDrift(t *testing.T) {
+	key, _ := totpEncoding.DecodeString(rfcTOTPSecret)
+	now := time.Unix(1700000000, 0)
+	current := now.Unix() / totpPeriod
+
+	tests := []struct {
+		name  string
+		step  int64
+		valid bool
+	}{
+		{"current step", current, true},
+		{"one step behind", current - 1, true},
+		{"one step ahead", current + 1, true},
+		{"two steps behind", current - 2, false},
+		{"two steps ahead", current + 2, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			step, ok := ValidateTOTP(rfcTOTPSecret, totpCode(key, test.step), now)
+			if ok != test.valid || (ok && step != test.step) {
+				t.Errorf("ValidateTOTP() = (%d, %v), want (%d, %v)", step, ok, test.step, test.valid)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPMalformed(t *testing.T) {
+	now := time.Unix(59, 0)
+
+	if _, ok := ValidateTOTP(rfcTOTPSecret, "28708", now); ok {
+		t.Error("ValidateTOTP() accepted a short code")
+	}
+	if _, ok := ValidateTOTP("not base32!", "287082", now); ok {
+		t.Error("ValidateTOTP() accepted an invalid secret")
+	}
+}